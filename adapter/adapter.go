@@ -3,6 +3,7 @@ package adapter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,30 +16,184 @@ type GoRedisAdapter struct {
 	goredis.UniversalClient
 }
 
-var _ redisstore.Client = (*GoRedisAdapter)(nil)
+var (
+	_ redisstore.Client       = (*GoRedisAdapter)(nil)
+	_ redisstore.KeyScanner   = (*GoRedisAdapter)(nil)
+	_ redisstore.TTLReader    = (*GoRedisAdapter)(nil)
+	_ redisstore.BatchClient  = (*GoRedisAdapter)(nil)
+	_ redisstore.GetDeleter   = (*GoRedisAdapter)(nil)
+	_ redisstore.MultiDeleter = (*GoRedisAdapter)(nil)
+	_ redisstore.Replacer     = (*GoRedisAdapter)(nil)
+)
 
 func UseGoRedis(client goredis.UniversalClient) *GoRedisAdapter {
 	return &GoRedisAdapter{client}
 }
 
 func (a *GoRedisAdapter) Get(ctx context.Context, key string) ([]byte, error) {
-	val, err := a.UniversalClient.Get(ctx, key).Result()
-	return []byte(val), err
+	val, err := a.UniversalClient.Get(ctx, key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, redisstore.ErrNotFound
+	}
+
+	return val, err
 }
 
 func (a *GoRedisAdapter) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return a.UniversalClient.Set(ctx, key, value, 0).Err()
+	return a.UniversalClient.Set(ctx, key, value, expiration).Err()
 }
 
 func (a *GoRedisAdapter) Del(ctx context.Context, key string) error {
 	return a.UniversalClient.Del(ctx, key).Err()
 }
 
+// DelMany deletes keys with a single DEL. With a cluster client, the keys of
+// different slots are deleted with a pipeline of DEL commands instead.
+func (a *GoRedisAdapter) DelMany(ctx context.Context, keys ...string) error {
+	if _, ok := a.UniversalClient.(*goredis.ClusterClient); ok {
+		_, err := a.UniversalClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+
+			return nil
+		})
+
+		return err
+	}
+
+	return a.UniversalClient.Del(ctx, keys...).Err()
+}
+
+func (a *GoRedisAdapter) GetDel(ctx context.Context, key string) ([]byte, error) {
+	val, err := a.UniversalClient.GetDel(ctx, key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, redisstore.ErrNotFound
+	}
+
+	return val, err
+}
+
+// Scan iterates over the keys of the server. With a cluster client, the keys
+// of all master nodes are returned one node after another.
+func (a *GoRedisAdapter) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if cluster, ok := a.UniversalClient.(*goredis.ClusterClient); ok {
+		return scanCluster(ctx, cluster, cursor, match, count)
+	}
+
+	return a.UniversalClient.Scan(ctx, cursor, match, count).Result()
+}
+
+func (a *GoRedisAdapter) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := a.UniversalClient.Do(ctx, "PTTL", key).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return pttl(ms)
+}
+
+// MGet reads keys with a single MGET. With a cluster client, the keys of
+// different slots are read with a pipeline of GET commands instead.
+func (a *GoRedisAdapter) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if _, ok := a.UniversalClient.(*goredis.ClusterClient); ok {
+		return a.pipelinedGet(ctx, keys)
+	}
+
+	values, err := a.UniversalClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, len(values))
+	for i, val := range values {
+		if s, ok := val.(string); ok {
+			result[i] = []byte(s)
+		}
+	}
+
+	return result, nil
+}
+
+func (a *GoRedisAdapter) pipelinedGet(ctx context.Context, keys []string) ([][]byte, error) {
+	cmds := make([]*goredis.StringCmd, len(keys))
+
+	_, err := a.UniversalClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, key)
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, err
+	}
+
+	result := make([][]byte, len(keys))
+	for i, cmd := range cmds {
+		val, err := cmd.Bytes()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result[i] = val
+	}
+
+	return result, nil
+}
+
+// SetMany sets all entries in a single pipeline.
+func (a *GoRedisAdapter) SetMany(ctx context.Context, entries ...redisstore.BatchEntry) error {
+	_, err := a.UniversalClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for _, entry := range entries {
+			pipe.Set(ctx, entry.Key, entry.Value, entry.Expiration)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Replace sets entry and deletes keys in a MULTI/EXEC transaction. With a
+// cluster client, the keys of different slots can not be part of the same
+// transaction, so the commands are sent in a pipeline without atomicity.
+func (a *GoRedisAdapter) Replace(ctx context.Context, entry redisstore.BatchEntry, keys ...string) error {
+	fn := func(pipe goredis.Pipeliner) error {
+		pipe.Set(ctx, entry.Key, entry.Value, entry.Expiration)
+		if len(keys) > 0 {
+			pipe.Del(ctx, keys...)
+		}
+
+		return nil
+	}
+
+	if _, ok := a.UniversalClient.(*goredis.ClusterClient); ok {
+		_, err := a.UniversalClient.Pipelined(ctx, fn)
+		return err
+	}
+
+	_, err := a.UniversalClient.TxPipelined(ctx, fn)
+
+	return err
+}
+
 type RedigoAdapter struct {
 	*redigo.Pool
 }
 
-var _ redisstore.Client = (*RedigoAdapter)(nil)
+var (
+	_ redisstore.Client       = (*RedigoAdapter)(nil)
+	_ redisstore.KeyScanner   = (*RedigoAdapter)(nil)
+	_ redisstore.TTLReader    = (*RedigoAdapter)(nil)
+	_ redisstore.BatchClient  = (*RedigoAdapter)(nil)
+	_ redisstore.GetDeleter   = (*RedigoAdapter)(nil)
+	_ redisstore.MultiDeleter = (*RedigoAdapter)(nil)
+	_ redisstore.Replacer     = (*RedigoAdapter)(nil)
+)
 
 func UseRedigo(pool *redigo.Pool) *RedigoAdapter {
 	return &RedigoAdapter{pool}
@@ -56,6 +211,10 @@ func (a *RedigoAdapter) Get(ctx context.Context, key string) ([]byte, error) {
 		return nil, fmt.Errorf("getting value from redis: %v", err)
 	}
 
+	if val == nil {
+		return nil, redisstore.ErrNotFound
+	}
+
 	v, ok := val.([]byte)
 	if !ok {
 		return nil, fmt.Errorf("value is not a []byte: %v", val)
@@ -93,3 +252,180 @@ func (a *RedigoAdapter) Del(ctx context.Context, key string) error {
 
 	return nil
 }
+
+func (a *RedigoAdapter) DelMany(ctx context.Context, keys ...string) error {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	_, err = redigo.DoContext(conn, ctx, "DEL", args...)
+	if err != nil {
+		return fmt.Errorf("deleting values from redis: %v", err)
+	}
+
+	return nil
+}
+
+func (a *RedigoAdapter) GetDel(ctx context.Context, key string) ([]byte, error) {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	val, err := redigo.Bytes(redigo.DoContext(conn, ctx, "GETDEL", key))
+	if errors.Is(err, redigo.ErrNil) {
+		return nil, redisstore.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting and deleting value from redis: %v", err)
+	}
+
+	return val, nil
+}
+
+func (a *RedigoAdapter) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	args := []interface{}{cursor}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	values, err := redigo.Values(redigo.DoContext(conn, ctx, "SCAN", args...))
+	if err != nil {
+		return nil, 0, fmt.Errorf("scanning keys in redis: %v", err)
+	}
+
+	var (
+		next uint64
+		keys []string
+	)
+	if _, err := redigo.Scan(values, &next, &keys); err != nil {
+		return nil, 0, fmt.Errorf("parsing scan reply: %v", err)
+	}
+
+	return keys, next, nil
+}
+
+func (a *RedigoAdapter) TTL(ctx context.Context, key string) (time.Duration, error) {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	ms, err := redigo.Int64(redigo.DoContext(conn, ctx, "PTTL", key))
+	if err != nil {
+		return 0, fmt.Errorf("getting ttl from redis: %v", err)
+	}
+
+	return pttl(ms)
+}
+
+func (a *RedigoAdapter) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	values, err := redigo.ByteSlices(redigo.DoContext(conn, ctx, "MGET", args...))
+	if err != nil {
+		return nil, fmt.Errorf("getting values from redis: %v", err)
+	}
+
+	return values, nil
+}
+
+// SetMany sends the SET commands of all entries before reading the replies.
+func (a *RedigoAdapter) SetMany(ctx context.Context, entries ...redisstore.BatchEntry) error {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	for _, entry := range entries {
+		if err := conn.Send("SET", entry.Key, entry.Value, "EX", int(entry.Expiration.Seconds())); err != nil {
+			return fmt.Errorf("sending command to redis: %v", err)
+		}
+	}
+
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("sending commands to redis: %v", err)
+	}
+
+	var firstErr error
+	for range entries {
+		if _, err := redigo.ReceiveContext(conn, ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("setting value in redis: %v", err)
+		}
+	}
+
+	return firstErr
+}
+
+// Replace sets entry and deletes keys in a MULTI/EXEC transaction.
+func (a *RedigoAdapter) Replace(ctx context.Context, entry redisstore.BatchEntry, keys ...string) error {
+	conn, err := a.Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.Send("MULTI"); err != nil {
+		return fmt.Errorf("sending command to redis: %v", err)
+	}
+	if err := conn.Send("SET", entry.Key, entry.Value, "EX", int(entry.Expiration.Seconds())); err != nil {
+		return fmt.Errorf("sending command to redis: %v", err)
+	}
+	if len(keys) > 0 {
+		args := make([]interface{}, len(keys))
+		for i, key := range keys {
+			args[i] = key
+		}
+
+		if err := conn.Send("DEL", args...); err != nil {
+			return fmt.Errorf("sending command to redis: %v", err)
+		}
+	}
+
+	if _, err := redigo.DoContext(conn, ctx, "EXEC"); err != nil {
+		return fmt.Errorf("replacing values in redis: %v", err)
+	}
+
+	return nil
+}
+
+// pttl converts a PTTL reply to the TTLReader semantics. Redis replies with -2
+// for missing keys and -1 for keys without an expiration.
+func pttl(ms int64) (time.Duration, error) {
+	switch ms {
+	case -2:
+		return 0, redisstore.ErrNotFound
+	case -1:
+		return -1, nil
+	default:
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+}
@@ -1,41 +1,200 @@
 package adapter
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/joelrose/redisstore"
 	goredis "github.com/redis/go-redis/v9"
+	"github.com/redis/rueidis"
 	"github.com/stretchr/testify/assert"
 )
 
-type storeFactory func(*testing.T) *redisstore.Store
+type storeFactory func(*testing.T, ...redisstore.Options) *redisstore.Store
 
-func TestGetSet_GoRedis(t *testing.T) {
-	GetSet(t, func(_ *testing.T) *redisstore.Store {
-		client := goredis.NewClient(&goredis.Options{
-			Addr: "localhost:6379",
-		})
+func newGoRedisStore(_ *testing.T, options ...redisstore.Options) *redisstore.Store {
+	client := goredis.NewClient(&goredis.Options{
+		Addr: "localhost:6379",
+	})
+
+	return redisstore.New(UseGoRedis(client), [][]byte{[]byte("secret")}, options...)
+}
+
+// newGoRedisClusterStore connects to localhost:6379 in cluster mode and skips
+// the test if cluster support is disabled.
+func newGoRedisClusterStore(t *testing.T, options ...redisstore.Options) *redisstore.Store {
+	t.Helper()
 
-		return redisstore.New(UseGoRedis(client), [][]byte{[]byte("secret")})
+	client := goredis.NewClusterClient(&goredis.ClusterOptions{
+		Addrs: []string{"localhost:6379"},
 	})
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.ClusterSlots(context.Background()).Err(); err != nil {
+		t.Skip("server does not support cluster mode: ", err)
+	}
+
+	options = append(options, redisstore.WithClusterHashTags())
+
+	return redisstore.New(UseGoRedis(client), [][]byte{[]byte("secret")}, options...)
+}
+
+func newRedigoStore(_ *testing.T, options ...redisstore.Options) *redisstore.Store {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379") // nolint: wrapcheck
+		},
+	}
+
+	return redisstore.New(UseRedigo(pool), [][]byte{[]byte("secret")}, options...)
+}
+
+func newRueidisClient(t *testing.T, disableCache bool) rueidis.Client {
+	t.Helper()
+
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:  []string{"localhost:6379"},
+		DisableCache: disableCache,
+	})
+	if errors.Is(err, rueidis.ErrNoCache) {
+		t.Skip("server does not support client-side caching: ", err)
+	}
+	if err != nil {
+		t.Fatal("failed to create rueidis client: ", err)
+	}
+	t.Cleanup(client.Close)
+
+	return client
+}
+
+func newRueidisStore(t *testing.T, options ...redisstore.Options) *redisstore.Store {
+	t.Helper()
+
+	return redisstore.New(UseRueidis(newRueidisClient(t, true)), [][]byte{[]byte("secret")}, options...)
+}
+
+func TestGetSet_GoRedis(t *testing.T) {
+	GetSet(t, newGoRedisStore)
+}
+
+func TestGetSet_GoRedisCluster(t *testing.T) {
+	GetSet(t, newGoRedisClusterStore)
 }
 
 func TestGetSet_Redigo(t *testing.T) {
-	GetSet(t, func(_ *testing.T) *redisstore.Store {
-		pool := &redis.Pool{
-			Dial: func() (redis.Conn, error) {
-				return redis.Dial("tcp", "localhost:6379") // nolint: wrapcheck
-			},
-		}
+	GetSet(t, newRedigoStore)
+}
+
+func TestGetSet_Rueidis(t *testing.T) {
+	GetSet(t, newRueidisStore)
+}
 
-		return redisstore.New(UseRedigo(pool), [][]byte{[]byte("secret")})
+func TestGetSet_RueidisClientSideCache(t *testing.T) {
+	GetSet(t, func(t *testing.T, options ...redisstore.Options) *redisstore.Store {
+		t.Helper()
+
+		client := UseRueidis(newRueidisClient(t, false), WithClientSideCache(time.Minute))
+
+		return redisstore.New(client, [][]byte{[]byte("secret")}, options...)
 	})
 }
 
+func TestRueidisClientSideCache(t *testing.T) {
+	ctx := context.Background()
+
+	cached := UseRueidis(newRueidisClient(t, false), WithClientSideCache(time.Minute))
+	other := UseRueidis(newRueidisClient(t, true))
+
+	assert.NoError(t, other.Set(ctx, "rueidis_cache", []byte("v1"), time.Minute))
+
+	val, err := cached.Get(ctx, "rueidis_cache")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("v1"), val)
+
+	// Writes of other clients invalidate the cached value.
+	assert.NoError(t, other.Set(ctx, "rueidis_cache", []byte("v2"), time.Minute))
+	assert.Eventually(t, func() bool {
+		val, err := cached.Get(ctx, "rueidis_cache")
+		return err == nil && string(val) == "v2"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, other.Del(ctx, "rueidis_cache"))
+	assert.Eventually(t, func() bool {
+		_, err := cached.Get(ctx, "rueidis_cache")
+		return errors.Is(err, redisstore.ErrNotFound)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAdmin_GoRedis(t *testing.T) {
+	Admin(t, newGoRedisStore)
+}
+
+func TestAdmin_GoRedisCluster(t *testing.T) {
+	Admin(t, newGoRedisClusterStore)
+}
+
+func TestAdmin_Redigo(t *testing.T) {
+	Admin(t, newRedigoStore)
+}
+
+func TestAdmin_Rueidis(t *testing.T) {
+	Admin(t, newRueidisStore)
+}
+
+func TestBatch_GoRedis(t *testing.T) {
+	Batch(t, newGoRedisStore)
+}
+
+func TestBatch_GoRedisCluster(t *testing.T) {
+	Batch(t, newGoRedisClusterStore)
+}
+
+func TestBatch_Redigo(t *testing.T) {
+	Batch(t, newRedigoStore)
+}
+
+func TestBatch_Rueidis(t *testing.T) {
+	Batch(t, newRueidisStore)
+}
+
+func TestFlashes_GoRedis(t *testing.T) {
+	Flashes(t, newGoRedisStore)
+}
+
+func TestFlashes_GoRedisCluster(t *testing.T) {
+	Flashes(t, newGoRedisClusterStore)
+}
+
+func TestFlashes_Redigo(t *testing.T) {
+	Flashes(t, newRedigoStore)
+}
+
+func TestFlashes_Rueidis(t *testing.T) {
+	Flashes(t, newRueidisStore)
+}
+
+func TestPromote_GoRedis(t *testing.T) {
+	Promote(t, newGoRedisStore)
+}
+
+func TestPromote_GoRedisCluster(t *testing.T) {
+	Promote(t, newGoRedisClusterStore)
+}
+
+func TestPromote_Redigo(t *testing.T) {
+	Promote(t, newRedigoStore)
+}
+
+func TestPromote_Rueidis(t *testing.T) {
+	Promote(t, newRueidisStore)
+}
+
 func GetSet(t *testing.T, newStore storeFactory) {
 	t.Helper()
 
@@ -107,6 +266,200 @@ func GetSet(t *testing.T, newStore storeFactory) {
 	assert.Equal(t, "", cookies[0].Value)
 }
 
+func Admin(t *testing.T, newStore storeFactory) {
+	t.Helper()
+
+	ctx := context.Background()
+	store := newStore(t, redisstore.WithKeyPrefix("admin_"))
+	requireCapability(t, store, "SCAN and PTTL", func(caps redisstore.Capabilities) bool {
+		return caps.Scan && caps.TTL
+	})
+
+	admin, err := redisstore.NewAdmin(store, redisstore.WithBatchSize(2))
+	assert.NoError(t, err)
+
+	_, err = admin.Purge(ctx, "")
+	assert.NoError(t, err)
+
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+
+		session.Values["index"] = i
+		assert.NoError(t, session.Save(req, httptest.NewRecorder()))
+
+		ids = append(ids, session.ID)
+	}
+
+	infos, err := admin.List(ctx, "")
+	assert.NoError(t, err)
+	assert.Len(t, infos, 3)
+	for _, info := range infos {
+		assert.Contains(t, ids, info.ID)
+		assert.Equal(t, store.SessionKey(info.ID), info.Key)
+		assert.Greater(t, info.Size, 0)
+		assert.Greater(t, info.TTL, time.Duration(0))
+		assert.LessOrEqual(t, info.TTL, time.Duration(store.Options.MaxAge)*time.Second)
+	}
+
+	info, err := admin.Inspect(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, info.Values["index"])
+
+	_, err = admin.Inspect(ctx, "missing")
+	assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+	assert.NoError(t, admin.Delete(ctx, ids[0]))
+
+	deleted, err := admin.Purge(ctx, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+}
+
+func Batch(t *testing.T, newStore storeFactory) {
+	t.Helper()
+
+	store := newStore(t)
+
+	req1, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	res1 := httptest.NewRecorder()
+
+	auth, err := store.New(req1, "auth")
+	assert.NoError(t, err)
+	auth.Values["user"] = "a"
+
+	cart, err := store.New(req1, "cart")
+	assert.NoError(t, err)
+	cart.Values["items"] = 2
+
+	assert.NoError(t, store.SaveMany(req1, res1, auth, cart))
+
+	req2, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	copyCookies(req2, res1)
+
+	loaded, err := store.LoadMany(req2, "auth", "cart", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", loaded["auth"].Values["user"])
+	assert.Equal(t, 2, loaded["cart"].Values["items"])
+	assert.True(t, loaded["missing"].IsNew)
+
+	loaded["cart"].Options.MaxAge = -1
+	assert.NoError(t, store.SaveMany(req2, httptest.NewRecorder(), loaded["auth"], loaded["cart"]))
+
+	req3, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	copyCookies(req3, res1)
+
+	loaded, err = store.LoadMany(req3, "auth", "cart")
+	assert.NoError(t, err)
+	assert.False(t, loaded["auth"].IsNew)
+	assert.True(t, loaded["cart"].IsNew)
+}
+
+func Flashes(t *testing.T, newStore storeFactory) {
+	t.Helper()
+
+	store := newStore(t)
+	// Servers without GETDEL consume flashes with GET and DEL.
+	requireCapability(t, store, "flashes", func(redisstore.Capabilities) bool { return true })
+
+	req1, _ := http.NewRequest(http.MethodPost, "/", nil) // nolint:noctx
+	res1 := httptest.NewRecorder()
+
+	session, err := store.New(req1, "session")
+	assert.NoError(t, err)
+	assert.NoError(t, store.AddFlash(req1, res1, session, "info", "saved"))
+
+	req2, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	copyCookies(req2, res1)
+
+	session, err = store.New(req2, "session")
+	assert.NoError(t, err)
+
+	flashes, err := store.Flashes(req2, session, "info")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"saved"}, flashes)
+
+	flashes, err = store.Flashes(req2, session, "info")
+	assert.NoError(t, err)
+	assert.Empty(t, flashes)
+}
+
+func Promote(t *testing.T, newStore storeFactory) {
+	t.Helper()
+
+	store := newStore(t)
+
+	req1, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	res1 := httptest.NewRecorder()
+
+	anonymous, err := store.New(req1, "cart")
+	assert.NoError(t, err)
+	anonymous.Values["items"] = 2
+	assert.NoError(t, store.Save(req1, res1, anonymous))
+
+	req2, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	copyCookies(req2, res1)
+	res2 := httptest.NewRecorder()
+
+	anonymous, err = store.New(req2, "cart")
+	assert.NoError(t, err)
+
+	authenticated, err := store.New(req2, "auth")
+	assert.NoError(t, err)
+	authenticated.Values["user"] = "a"
+
+	assert.NoError(t, store.Promote(req2, res2, anonymous, authenticated, nil))
+
+	// The anonymous cookie is cleared and its session deleted.
+	cookies := res2.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	for _, cookie := range cookies {
+		if cookie.Name == "cart" {
+			assert.Equal(t, -1, cookie.MaxAge)
+		}
+	}
+
+	cart, err := store.New(req2, "cart")
+	assert.NoError(t, err)
+	assert.True(t, cart.IsNew)
+
+	req3, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	for _, cookie := range cookies {
+		if cookie.Name == "auth" {
+			req3.AddCookie(cookie)
+		}
+	}
+
+	loaded, err := store.LoadMany(req3, "auth")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", loaded["auth"].Values["user"])
+	assert.Equal(t, 2, loaded["auth"].Values["items"])
+}
+
+// requireCapability probes the server of store, so the store falls back for
+// missing commands, and skips the test if the server lacks a capability.
+func requireCapability(
+	t *testing.T,
+	store *redisstore.Store,
+	name string,
+	supported func(caps redisstore.Capabilities) bool,
+) redisstore.Capabilities {
+	t.Helper()
+
+	caps, err := store.Probe(context.Background())
+	if err != nil {
+		t.Fatal("failed to probe server: ", err)
+	}
+
+	if !supported(caps) {
+		t.Skipf("server does not support %s", name)
+	}
+
+	return caps
+}
+
 func copyCookies(req *http.Request, res *httptest.ResponseRecorder) {
 	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
 }
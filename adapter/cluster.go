@@ -0,0 +1,97 @@
+// nolint: wrapcheck
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// clusterCursorShift is the bit offset of the node index in a cluster scan
+// cursor. The lower bits hold the cursor of the node, which is bounded by the
+// size of its hash table.
+const clusterCursorShift = 48
+
+const clusterCursorMask = 1<<clusterCursorShift - 1
+
+// scanCluster iterates over the keys of all master nodes of a cluster. The
+// index of the scanned node is stored in the upper bits of the cursor. Keys
+// that move between nodes while scanning may be skipped or returned twice.
+func scanCluster(
+	ctx context.Context,
+	client *goredis.ClusterClient,
+	cursor uint64,
+	match string,
+	count int64,
+) ([]string, uint64, error) {
+	masters, err := clusterMasters(ctx, client)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return scanNodes(cursor, len(masters), func(node int, cursor uint64) ([]string, uint64, error) {
+		return masters[node].Scan(ctx, cursor, match, count).Result()
+	})
+}
+
+// scanNodes continues a scan over a number of nodes, calling scan with the
+// index and cursor of the current node. The index of the node is stored in the
+// upper bits of the returned cursor.
+func scanNodes(
+	cursor uint64,
+	nodes int,
+	scan func(node int, cursor uint64) ([]string, uint64, error),
+) ([]string, uint64, error) {
+	node := cursor >> clusterCursorShift
+	if node >= uint64(nodes) {
+		return nil, 0, fmt.Errorf("invalid cursor %d for %d master nodes", cursor, nodes)
+	}
+
+	keys, next, err := scan(int(node), cursor&clusterCursorMask)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if next > clusterCursorMask {
+		return nil, 0, fmt.Errorf("cursor %d of node %d out of range", next, node)
+	}
+
+	if next == 0 {
+		node++
+		if node == uint64(nodes) {
+			return keys, 0, nil
+		}
+	}
+
+	return keys, node<<clusterCursorShift | next, nil
+}
+
+// clusterMasters returns the master nodes of a cluster ordered by address, so
+// that node indexes are stable between calls.
+func clusterMasters(ctx context.Context, client *goredis.ClusterClient) ([]*goredis.Client, error) {
+	var (
+		mu      sync.Mutex
+		masters []*goredis.Client
+	)
+
+	err := client.ForEachMaster(ctx, func(ctx context.Context, master *goredis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		masters = append(masters, master)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Options().Addr < masters[j].Options().Addr
+	})
+
+	return masters, nil
+}
@@ -0,0 +1,38 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanNodes(t *testing.T) {
+	// Every node returns its keys in two pages.
+	nodes := [][]string{{"a1", "a2"}, {"b1", "b2"}, {"c1", "c2"}}
+	scan := func(node int, cursor uint64) ([]string, uint64, error) {
+		if cursor == 0 {
+			return nodes[node][:1], 7, nil
+		}
+
+		return nodes[node][1:], 0, nil
+	}
+
+	var keys []string
+	var cursor uint64
+	for {
+		page, next, err := scanNodes(cursor, len(nodes), scan)
+		require.NoError(t, err)
+
+		keys = append(keys, page...)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	assert.Equal(t, []string{"a1", "a2", "b1", "b2", "c1", "c2"}, keys)
+
+	_, _, err := scanNodes(3<<clusterCursorShift, len(nodes), scan)
+	assert.Error(t, err)
+}
@@ -0,0 +1,366 @@
+// nolint: wrapcheck
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/joelrose/redisstore"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/redis/rueidis"
+)
+
+var (
+	_ redisstore.CapabilityProber = (*GoRedisAdapter)(nil)
+	_ redisstore.CapabilityProber = (*RedigoAdapter)(nil)
+	_ redisstore.CapabilityProber = (*RueidisAdapter)(nil)
+)
+
+// probeKey is used by commands that need a key to be probed. It is never
+// written.
+const probeKey = "redisstore:probe"
+
+// probeNotifications is the channel pattern subscribed to by the probe of
+// keyspace notifications.
+const probeNotifications = "__keyevent@*__:expired"
+
+// probeSubscribeTimeout bounds the wait for a subscription confirmation.
+const probeSubscribeTimeout = 5 * time.Second
+
+// commander runs the raw commands needed to probe a server.
+type commander interface {
+	// do runs a command and returns its reply as a string if it is one.
+	do(ctx context.Context, args ...interface{}) (string, error)
+	// configGet returns the value of a configuration parameter and reports
+	// whether the server knows it.
+	configGet(ctx context.Context, parameter string) (string, bool, error)
+	// psubscribe subscribes to pattern on a dedicated connection, waits for
+	// the confirmation and unsubscribes again.
+	psubscribe(ctx context.Context, pattern string) error
+	// isServerError reports whether err is an error reply of the server, as
+	// opposed to e.g. a connection error.
+	isServerError(err error) bool
+}
+
+// probe detects the capabilities of a server by running every command the
+// store may use. Commands that the server rejects are reported as unsupported.
+func probe(ctx context.Context, c commander) (redisstore.Capabilities, error) {
+	var caps redisstore.Capabilities
+
+	// INFO is only used to identify the server, so failures are ignored.
+	if info, err := c.do(ctx, "INFO", "server"); err == nil {
+		caps.Server, caps.Version = parseServerInfo(info)
+	} else if !c.isServerError(err) {
+		return caps, err
+	}
+
+	var (
+		notifyFlags string
+		hasFlags    bool
+	)
+
+	checks := []struct {
+		supported *bool
+		run       func() error
+	}{
+		{&caps.Scan, func() error { _, err := c.do(ctx, "SCAN", 0, "MATCH", probeKey, "COUNT", 1); return err }},
+		{&caps.TTL, func() error { _, err := c.do(ctx, "PTTL", probeKey); return err }},
+		{&caps.GetDel, func() error { _, err := c.do(ctx, "GETDEL", probeKey); return err }},
+		{&caps.Config, func() (err error) {
+			notifyFlags, hasFlags, err = c.configGet(ctx, "notify-keyspace-events")
+			return err
+		}},
+		{&caps.KeyspaceEvents, func() error { return c.psubscribe(ctx, probeNotifications) }},
+	}
+
+	for _, check := range checks {
+		err := check.run()
+		if err != nil && !c.isServerError(err) {
+			return caps, err
+		}
+		*check.supported = err == nil
+	}
+
+	// Dragonfly only publishes keyevent notifications for expired keys.
+	caps.DelEvents = caps.KeyspaceEvents && caps.Server != "dragonfly"
+
+	// A subscription succeeds even if no notifications are published, so the
+	// configured flags decide where they can be read.
+	if caps.Config && hasFlags {
+		caps.KeyspaceEvents = caps.KeyspaceEvents && notifyFlagsEnabled(notifyFlags, "Ex")
+		caps.DelEvents = caps.DelEvents && notifyFlagsEnabled(notifyFlags, "Eg")
+	}
+
+	return caps, nil
+}
+
+// notifyFlagsEnabled reports whether a notify-keyspace-events value enables
+// all of the given flags.
+func notifyFlagsEnabled(current, flags string) bool {
+	for _, flag := range flags {
+		if !notifyFlagEnabled(current, flag) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// notifyFlagEnabled reports whether a notify-keyspace-events value enables a
+// flag. "A" is an alias for all event classes "g$lshzxetd".
+func notifyFlagEnabled(current string, flag rune) bool {
+	if strings.ContainsRune(current, flag) {
+		return true
+	}
+
+	return strings.ContainsRune("g$lshzxetd", flag) && strings.ContainsRune(current, 'A')
+}
+
+// parseServerInfo returns the server name and version from an INFO server
+// reply.
+func parseServerInfo(info string) (string, string) {
+	fields := make(map[string]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":"); ok {
+			fields[key] = value
+		}
+	}
+
+	switch {
+	case fields["dragonfly_version"] != "":
+		return "dragonfly", fields["dragonfly_version"]
+	case fields["valkey_version"] != "":
+		return "valkey", fields["valkey_version"]
+	case strings.Contains(fields["executable"], "keydb"):
+		return "keydb", fields["redis_version"]
+	case fields["redis_version"] != "":
+		return "redis", fields["redis_version"]
+	default:
+		return "", ""
+	}
+}
+
+// Probe detects the capabilities of the server.
+func (a *GoRedisAdapter) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	return probe(ctx, goRedisCommander{a.UniversalClient})
+}
+
+// Probe detects the capabilities of the server.
+func (a *RedigoAdapter) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	return probe(ctx, redigoCommander{a.Pool})
+}
+
+// Probe detects the capabilities of the server.
+func (a *RueidisAdapter) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	return probe(ctx, rueidisCommander{a.Client})
+}
+
+type goRedisCommander struct {
+	client goredis.UniversalClient
+}
+
+func (c goRedisCommander) do(ctx context.Context, args ...interface{}) (string, error) {
+	val, err := c.client.Do(ctx, args...).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", nil
+	}
+
+	s, _ := val.(string)
+
+	return s, err
+}
+
+func (c goRedisCommander) configGet(ctx context.Context, parameter string) (string, bool, error) {
+	values, err := c.client.ConfigGet(ctx, parameter).Result()
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := values[parameter]
+
+	return value, ok, nil
+}
+
+func (c goRedisCommander) psubscribe(ctx context.Context, pattern string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeSubscribeTimeout)
+	defer cancel()
+
+	pubsub := c.client.PSubscribe(ctx)
+	defer pubsub.Close()
+
+	if err := pubsub.PSubscribe(ctx, pattern); err != nil {
+		return err
+	}
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	return pubsub.PUnsubscribe(ctx, pattern)
+}
+
+func (c goRedisCommander) isServerError(err error) bool {
+	var redisErr goredis.Error
+
+	return errors.As(err, &redisErr)
+}
+
+type redigoCommander struct {
+	pool *redigo.Pool
+}
+
+func (c redigoCommander) do(ctx context.Context, args ...interface{}) (string, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	val, err := redigo.DoContext(conn, ctx, args[0].(string), args[1:]...)
+	if err != nil {
+		return "", err
+	}
+
+	if b, ok := val.([]byte); ok {
+		return string(b), nil
+	}
+
+	return "", nil
+}
+
+func (c redigoCommander) configGet(ctx context.Context, parameter string) (string, bool, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	values, err := redigo.StringMap(redigo.DoContext(conn, ctx, "CONFIG", "GET", parameter))
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := values[parameter]
+
+	return value, ok, nil
+}
+
+func (c redigoCommander) psubscribe(ctx context.Context, pattern string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeSubscribeTimeout)
+	defer cancel()
+
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	psc := redigo.PubSubConn{Conn: conn}
+	if err := psc.PSubscribe(pattern); err != nil {
+		return err
+	}
+
+	switch reply := psc.ReceiveContext(ctx).(type) {
+	case error:
+		return reply
+	case redigo.Subscription:
+	default:
+		return fmt.Errorf("unexpected reply to PSUBSCRIBE: %v", reply)
+	}
+
+	return psc.PUnsubscribe(pattern)
+}
+
+func (c redigoCommander) isServerError(err error) bool {
+	var redisErr redigo.Error
+
+	return errors.As(err, &redisErr)
+}
+
+type rueidisCommander struct {
+	client rueidis.Client
+}
+
+func (c rueidisCommander) do(ctx context.Context, args ...interface{}) (string, error) {
+	cmd := make([]string, 0, len(args))
+	for _, arg := range args {
+		cmd = append(cmd, fmt.Sprint(arg))
+	}
+
+	msg, err := c.client.Do(ctx, c.client.B().Arbitrary(cmd...).Build()).ToMessage()
+	if err != nil {
+		if rueidis.IsRedisNil(err) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if !msg.IsString() {
+		return "", nil
+	}
+
+	return msg.ToString()
+}
+
+func (c rueidisCommander) configGet(ctx context.Context, parameter string) (string, bool, error) {
+	values, err := c.client.Do(ctx, c.client.B().ConfigGet().Parameter(parameter).Build()).AsStrMap()
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := values[parameter]
+
+	return value, ok, nil
+}
+
+func (c rueidisCommander) psubscribe(ctx context.Context, pattern string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeSubscribeTimeout)
+	defer cancel()
+
+	client, release := c.client.Dedicate()
+	defer release()
+
+	subscribed := make(chan struct{}, 1)
+	wait := client.SetPubSubHooks(rueidis.PubSubHooks{
+		OnSubscription: func(s rueidis.PubSubSubscription) {
+			if s.Kind == "psubscribe" {
+				select {
+				case subscribed <- struct{}{}:
+				default:
+				}
+			}
+		},
+	})
+
+	if err := client.Do(ctx, client.B().Psubscribe().Pattern(pattern).Build()).Error(); err != nil {
+		return err
+	}
+
+	select {
+	case <-subscribed:
+	case err := <-wait:
+		if err == nil {
+			err = errors.New("subscription closed")
+		}
+
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return client.Do(ctx, client.B().Punsubscribe().Pattern(pattern).Build()).Error()
+}
+
+func (c rueidisCommander) isServerError(err error) bool {
+	var redisErr *rueidis.RedisError
+
+	return errors.As(err, &redisErr)
+}
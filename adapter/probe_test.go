@@ -0,0 +1,83 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joelrose/redisstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServerInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    string
+		server  string
+		version string
+	}{
+		{
+			name:    "redis",
+			info:    "# Server\r\nredis_version:7.0.9\r\nredis_mode:standalone\r\n",
+			server:  "redis",
+			version: "7.0.9",
+		},
+		{
+			name:    "valkey",
+			info:    "# Server\r\nredis_version:7.2.4\r\nserver_name:valkey\r\nvalkey_version:8.0.1\r\n",
+			server:  "valkey",
+			version: "8.0.1",
+		},
+		{
+			name:    "keydb",
+			info:    "# Server\r\nredis_version:6.3.4\r\nexecutable:/usr/local/bin/keydb-server\r\n",
+			server:  "keydb",
+			version: "6.3.4",
+		},
+		{
+			name:    "dragonfly",
+			info:    "# Server\r\nredis_version:6.2.11\r\ndragonfly_version:df-v1.14.0\r\n",
+			server:  "dragonfly",
+			version: "df-v1.14.0",
+		},
+		{name: "unknown", info: "# Clients\r\nconnected_clients:1\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, version := parseServerInfo(tt.info)
+			assert.Equal(t, tt.server, server)
+			assert.Equal(t, tt.version, version)
+		})
+	}
+}
+
+func TestProbe_GoRedis(t *testing.T) {
+	Probe(t, newGoRedisStore)
+}
+
+func TestProbe_Redigo(t *testing.T) {
+	Probe(t, newRedigoStore)
+}
+
+func TestProbe_Rueidis(t *testing.T) {
+	Probe(t, newRueidisStore)
+}
+
+func Probe(t *testing.T, newStore storeFactory) {
+	t.Helper()
+
+	store := newStore(t)
+
+	caps, err := store.Probe(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, caps, store.Capabilities())
+
+	// Every supported server implements the commands used by Admin.
+	assert.True(t, caps.Scan)
+	assert.True(t, caps.TTL)
+
+	_, err = redisstore.NewAdmin(store)
+	assert.NoError(t, err)
+
+	t.Logf("probed capabilities: %+v", caps)
+}
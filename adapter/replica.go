@@ -0,0 +1,333 @@
+// nolint: wrapcheck
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/joelrose/redisstore"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// ReplicaAdapter reads sessions from a replica and writes them to the
+// primary. Because replication is asynchronous, a session that was just
+// written may be missing or outdated on the replica:
+//
+//   - Reads that miss or fail on the replica are retried on the primary, so a
+//     new session is never reported as missing.
+//   - Keys written or deleted by this adapter are read from the primary until
+//     the replication lag has passed.
+//
+// Other processes may still read outdated sessions from the replica for the
+// duration of the replication lag.
+type ReplicaAdapter struct {
+	primary redisstore.Client
+	replica redisstore.Client
+	lag     time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	written   map[string]time.Time
+	nextSweep time.Time
+}
+
+var (
+	_ redisstore.Client           = (*ReplicaAdapter)(nil)
+	_ redisstore.KeyScanner       = (*ReplicaAdapter)(nil)
+	_ redisstore.TTLReader        = (*ReplicaAdapter)(nil)
+	_ redisstore.BatchClient      = (*ReplicaAdapter)(nil)
+	_ redisstore.GetDeleter       = (*ReplicaAdapter)(nil)
+	_ redisstore.MultiDeleter     = (*ReplicaAdapter)(nil)
+	_ redisstore.CapabilityProber = (*ReplicaAdapter)(nil)
+)
+
+// ReplicaOption configures a ReplicaAdapter.
+type ReplicaOption func(a *ReplicaAdapter)
+
+// WithReplicationLag sets how long keys written by the adapter are read from
+// the primary. By default, the lag is one second.
+func WithReplicationLag(lag time.Duration) ReplicaOption {
+	return func(a *ReplicaAdapter) {
+		a.lag = lag
+	}
+}
+
+const defaultReplicationLag = time.Second
+
+// UseReplica returns an adapter that reads from replica and writes to primary.
+func UseReplica(primary, replica redisstore.Client, options ...ReplicaOption) *ReplicaAdapter {
+	a := &ReplicaAdapter{
+		primary:   primary,
+		replica:   replica,
+		lag:       defaultReplicationLag,
+		now:       time.Now,
+		mu:        sync.Mutex{},
+		written:   make(map[string]time.Time),
+		nextSweep: time.Time{},
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a
+}
+
+// UseGoRedisFailover returns an adapter for a redis sentinel setup. Writes go
+// to the current master and reads to a replica, both resolved by sentinel and
+// updated on failover.
+func UseGoRedisFailover(opt *goredis.FailoverOptions, options ...ReplicaOption) *ReplicaAdapter {
+	replicaOpt := *opt
+	replicaOpt.ReplicaOnly = true
+
+	return UseReplica(
+		UseGoRedis(goredis.NewFailoverClient(opt)),
+		UseGoRedis(goredis.NewFailoverClient(&replicaOpt)),
+		options...,
+	)
+}
+
+// UseRedigoReplica returns an adapter that reads from the replica pool and
+// writes to the primary pool. Redigo does not follow failovers, so the pools
+// must dial addresses that are updated on failover, e.g. through a proxy.
+func UseRedigoReplica(primary, replica *redigo.Pool, options ...ReplicaOption) *ReplicaAdapter {
+	return UseReplica(UseRedigo(primary), UseRedigo(replica), options...)
+}
+
+func (a *ReplicaAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	if a.recentlyWritten(key) {
+		return a.primary.Get(ctx, key)
+	}
+
+	val, err := a.replica.Get(ctx, key)
+	if err == nil {
+		return val, nil
+	}
+
+	return a.primary.Get(ctx, key)
+}
+
+func (a *ReplicaAdapter) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	a.markWritten(key)
+
+	return a.primary.Set(ctx, key, value, expiration)
+}
+
+func (a *ReplicaAdapter) Del(ctx context.Context, key string) error {
+	a.markWritten(key)
+
+	return a.primary.Del(ctx, key)
+}
+
+// DelMany deletes keys on the primary.
+func (a *ReplicaAdapter) DelMany(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		a.markWritten(key)
+	}
+
+	deleter, ok := a.primary.(redisstore.MultiDeleter)
+	if !ok {
+		for _, key := range keys {
+			if err := a.primary.Del(ctx, key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return deleter.DelMany(ctx, keys...)
+}
+
+// GetDel reads and deletes a key on the primary. If the primary does not
+// implement redisstore.GetDeleter, the key is read and deleted with separate
+// commands.
+func (a *ReplicaAdapter) GetDel(ctx context.Context, key string) ([]byte, error) {
+	a.markWritten(key)
+
+	if deleter, ok := a.primary.(redisstore.GetDeleter); ok {
+		return deleter.GetDel(ctx, key)
+	}
+
+	val, err := a.primary.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.primary.Del(ctx, key); err != nil {
+		return nil, err
+	}
+
+	return val, nil
+}
+
+// Scan iterates over the keys of the primary.
+func (a *ReplicaAdapter) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	scanner, ok := a.primary.(redisstore.KeyScanner)
+	if !ok {
+		return nil, 0, redisstore.ErrScanNotSupported
+	}
+
+	return scanner.Scan(ctx, cursor, match, count)
+}
+
+// TTL reads the expiration of a key from the primary.
+func (a *ReplicaAdapter) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reader, ok := a.primary.(redisstore.TTLReader)
+	if !ok {
+		return 0, errors.New("primary does not support reading ttls")
+	}
+
+	return reader.TTL(ctx, key)
+}
+
+// MGet reads keys from the replica and retries the keys that are missing or
+// recently written on the primary.
+func (a *ReplicaAdapter) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+
+	retry := make([]int, 0, len(keys))
+	replicaKeys := make([]string, 0, len(keys))
+	replicaIndexes := make([]int, 0, len(keys))
+	for i, key := range keys {
+		if a.recentlyWritten(key) {
+			retry = append(retry, i)
+			continue
+		}
+		replicaKeys = append(replicaKeys, key)
+		replicaIndexes = append(replicaIndexes, i)
+	}
+
+	if len(replicaKeys) > 0 {
+		replicaValues, err := mget(ctx, a.replica, replicaKeys)
+		if err != nil {
+			retry = append(retry, replicaIndexes...)
+		} else {
+			for j, i := range replicaIndexes {
+				if replicaValues[j] == nil {
+					retry = append(retry, i)
+				}
+				values[i] = replicaValues[j]
+			}
+		}
+	}
+
+	if len(retry) == 0 {
+		return values, nil
+	}
+
+	primaryKeys := make([]string, len(retry))
+	for j, i := range retry {
+		primaryKeys[j] = keys[i]
+	}
+
+	primaryValues, err := mget(ctx, a.primary, primaryKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range retry {
+		values[i] = primaryValues[j]
+	}
+
+	return values, nil
+}
+
+// SetMany writes all entries to the primary.
+func (a *ReplicaAdapter) SetMany(ctx context.Context, entries ...redisstore.BatchEntry) error {
+	for _, entry := range entries {
+		a.markWritten(entry.Key)
+	}
+
+	if batch, ok := a.primary.(redisstore.BatchClient); ok {
+		return batch.SetMany(ctx, entries...)
+	}
+
+	for _, entry := range entries {
+		if err := a.primary.Set(ctx, entry.Key, entry.Value, entry.Expiration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Probe detects the capabilities of the primary.
+func (a *ReplicaAdapter) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	prober, ok := a.primary.(redisstore.CapabilityProber)
+	if !ok {
+		return redisstore.Capabilities{}, redisstore.ErrProbeNotSupported //nolint: exhaustruct
+	}
+
+	return prober.Probe(ctx)
+}
+
+// markWritten routes reads of key to the primary until the replication lag
+// has passed.
+func (a *ReplicaAdapter) markWritten(key string) {
+	if a.lag <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	a.written[key] = now.Add(a.lag)
+
+	// Drop expired marks once per lag, so that the map stays bounded by the
+	// number of keys written within two lags.
+	if now.After(a.nextSweep) {
+		for k, until := range a.written {
+			if !now.Before(until) {
+				delete(a.written, k)
+			}
+		}
+		a.nextSweep = now.Add(a.lag)
+	}
+}
+
+func (a *ReplicaAdapter) recentlyWritten(key string) bool {
+	if a.lag <= 0 {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	until, ok := a.written[key]
+	if !ok {
+		return false
+	}
+
+	if !a.now().Before(until) {
+		delete(a.written, key)
+		return false
+	}
+
+	return true
+}
+
+// mget reads keys with MGet if the client supports it or one by one.
+func mget(ctx context.Context, client redisstore.Client, keys []string) ([][]byte, error) {
+	if batch, ok := client.(redisstore.BatchClient); ok {
+		return batch.MGet(ctx, keys...)
+	}
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		val, err := client.Get(ctx, key)
+		if errors.Is(err, redisstore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[i] = val
+	}
+
+	return values, nil
+}
@@ -0,0 +1,150 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/memstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// replicate copies key from primary to replica like an asynchronous
+// replication stream.
+func replicate(t *testing.T, primary, replica *memstore.Client, key string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	val, err := primary.Get(ctx, key)
+	if errors.Is(err, redisstore.ErrNotFound) {
+		assert.NoError(t, replica.Del(ctx, key))
+		return
+	}
+	assert.NoError(t, err)
+	assert.NoError(t, replica.Set(ctx, key, val, 0))
+}
+
+func TestReplicaAdapter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads from replica", func(t *testing.T) {
+		primary, replica := memstore.New(), memstore.New()
+		a := UseReplica(primary, replica, WithReplicationLag(0))
+
+		assert.NoError(t, replica.Set(ctx, "key", "replica", 0))
+		assert.NoError(t, primary.Set(ctx, "key", "primary", 0))
+
+		val, err := a.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("replica"), val)
+	})
+
+	t.Run("falls back to primary", func(t *testing.T) {
+		primary, replica := memstore.New(), memstore.New()
+		a := UseReplica(primary, replica, WithReplicationLag(0))
+
+		assert.NoError(t, a.Set(ctx, "key", "value", 0))
+		val, err := a.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		replicate(t, primary, replica, "key")
+		replica.Fail(memstore.OpGet, errors.New("down"))
+		val, err = a.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		_, err = a.Get(ctx, "missing")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+	})
+
+	t.Run("reads own writes from primary", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		primary, replica := memstore.New(), memstore.New()
+		a := UseReplica(primary, replica, WithReplicationLag(time.Second))
+		a.now = func() time.Time { return now }
+
+		assert.NoError(t, a.Set(ctx, "key", "v1", 0))
+		replicate(t, primary, replica, "key")
+		assert.NoError(t, a.Set(ctx, "key", "v2", 0))
+
+		val, err := a.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("v2"), val)
+
+		values, err := a.MGet(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("v2")}, values)
+
+		// A deleted key is not read from the outdated replica.
+		assert.NoError(t, a.Del(ctx, "key"))
+		_, err = a.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		now = now.Add(time.Second)
+		val, err = a.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("v1"), val)
+		assert.Empty(t, a.written)
+	})
+
+	t.Run("mget", func(t *testing.T) {
+		primary, replica := memstore.New(), memstore.New()
+		a := UseReplica(primary, replica, WithReplicationLag(0))
+
+		assert.NoError(t, a.SetMany(ctx,
+			redisstore.BatchEntry{Key: "a", Value: "1", Expiration: 0},
+			redisstore.BatchEntry{Key: "b", Value: "2", Expiration: 0},
+		))
+		replicate(t, primary, replica, "a")
+
+		values, err := a.MGet(ctx, "a", "b", "missing")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("1"), []byte("2"), nil}, values)
+	})
+
+	t.Run("getdel without primary support", func(t *testing.T) {
+		primary, replica := memstore.New(), memstore.New()
+		// Hide the optional capabilities of the primary.
+		a := UseReplica(struct{ redisstore.Client }{primary}, replica, WithReplicationLag(0))
+
+		assert.NoError(t, a.Set(ctx, "key", "value", 0))
+		val, err := a.GetDel(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		_, err = primary.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		_, err = a.GetDel(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+	})
+}
+
+func TestReplicaAdapter_Store(t *testing.T) {
+	primary, replica := memstore.New(), memstore.New()
+	store := redisstore.New(UseReplica(primary, replica), [][]byte{[]byte("secret")})
+
+	req1, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	res1 := httptest.NewRecorder()
+
+	session, err := store.New(req1, "session")
+	assert.NoError(t, err)
+	session.Values["key"] = "value"
+	assert.NoError(t, session.Save(req1, res1))
+	assert.Equal(t, 0, replica.Len())
+
+	// The session is not replicated yet, but must not be treated as new.
+	req2, _ := http.NewRequest(http.MethodGet, "/", nil) // nolint:noctx
+	copyCookies(req2, res1)
+
+	session, err = store.New(req2, "session")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, "value", session.Values["key"])
+}
@@ -0,0 +1,365 @@
+// nolint: wrapcheck
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/redis/rueidis"
+)
+
+// RueidisAdapter implements redisstore.Client with rueidis. It supports
+// single node, sentinel and cluster clients.
+type RueidisAdapter struct {
+	rueidis.Client
+	cacheTTL time.Duration
+	cluster  bool
+}
+
+// rueidisNoSlot is set in the slot of every command built by a client that
+// does not route commands by slot, i.e. a single node or sentinel client.
+const rueidisNoSlot = 1 << 15
+
+var (
+	_ redisstore.Client       = (*RueidisAdapter)(nil)
+	_ redisstore.KeyScanner   = (*RueidisAdapter)(nil)
+	_ redisstore.TTLReader    = (*RueidisAdapter)(nil)
+	_ redisstore.BatchClient  = (*RueidisAdapter)(nil)
+	_ redisstore.GetDeleter   = (*RueidisAdapter)(nil)
+	_ redisstore.MultiDeleter = (*RueidisAdapter)(nil)
+	_ redisstore.Replacer     = (*RueidisAdapter)(nil)
+)
+
+// RueidisOption configures a RueidisAdapter.
+type RueidisOption func(a *RueidisAdapter)
+
+// WithClientSideCache serves session reads from the rueidis client-side cache
+// for up to ttl. Redis invalidates cached sessions once they are changed, so
+// reads never return stale sessions while the connection is healthy.
+// Client-side caching requires RESP3, i.e. redis 6 or newer, and a client
+// created without ClientOption.DisableCache.
+func WithClientSideCache(ttl time.Duration) RueidisOption {
+	return func(a *RueidisAdapter) {
+		a.cacheTTL = ttl
+	}
+}
+
+func UseRueidis(client rueidis.Client, options ...RueidisOption) *RueidisAdapter {
+	multi := client.B().Multi().Build()
+	a := &RueidisAdapter{
+		Client:   client,
+		cacheTTL: 0,
+		cluster:  multi.Slot()&rueidisNoSlot == 0,
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a
+}
+
+func (a *RueidisAdapter) Get(ctx context.Context, key string) ([]byte, error) {
+	var result rueidis.RedisResult
+	if a.cacheTTL > 0 {
+		result = a.Client.DoCache(ctx, a.Client.B().Get().Key(key).Cache(), a.cacheTTL)
+	} else {
+		result = a.Client.Do(ctx, a.Client.B().Get().Key(key).Build())
+	}
+
+	val, err := result.AsBytes()
+	if rueidis.IsRedisNil(err) {
+		return nil, redisstore.ErrNotFound
+	}
+
+	return val, err
+}
+
+func (a *RueidisAdapter) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return a.Client.Do(ctx, a.set(key, value, expiration)).Error()
+}
+
+// set builds a SET command.
+func (a *RueidisAdapter) set(key string, value interface{}, expiration time.Duration) rueidis.Completed {
+	var val string
+	switch v := value.(type) {
+	case []byte:
+		val = rueidis.BinaryString(v)
+	case string:
+		val = v
+	default:
+		val = fmt.Sprint(v)
+	}
+
+	set := a.Client.B().Set().Key(key).Value(val)
+	if expiration > 0 {
+		return set.Px(expiration).Build()
+	}
+
+	return set.Build()
+}
+
+func (a *RueidisAdapter) Del(ctx context.Context, key string) error {
+	return a.Client.Do(ctx, a.Client.B().Del().Key(key).Build()).Error()
+}
+
+// DelMany deletes keys with a pipeline of DEL commands, which also works for
+// the keys of different slots of a cluster.
+func (a *RueidisAdapter) DelMany(ctx context.Context, keys ...string) error {
+	cmds := make(rueidis.Commands, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, a.Client.B().Del().Key(key).Build())
+	}
+
+	for _, result := range a.Client.DoMulti(ctx, cmds...) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *RueidisAdapter) GetDel(ctx context.Context, key string) ([]byte, error) {
+	val, err := a.Client.Do(ctx, a.Client.B().Getdel().Key(key).Build()).AsBytes()
+	if rueidis.IsRedisNil(err) {
+		return nil, redisstore.ErrNotFound
+	}
+
+	return val, err
+}
+
+// Scan iterates over the keys of the server. With a cluster client, it
+// iterates over all master nodes like GoRedisAdapter.Scan.
+func (a *RueidisAdapter) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if !a.cluster {
+		return scanNode(ctx, a.Client, cursor, match, count)
+	}
+
+	masters, err := rueidisMasters(ctx, a.Client.Nodes())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return scanNodes(cursor, len(masters), func(node int, cursor uint64) ([]string, uint64, error) {
+		return scanNode(ctx, masters[node], cursor, match, count)
+	})
+}
+
+// scanNode iterates over the keys of a single node.
+func scanNode(
+	ctx context.Context,
+	client rueidis.Client,
+	cursor uint64,
+	match string,
+	count int64,
+) ([]string, uint64, error) {
+	cmd := client.B().Scan().Cursor(cursor)
+
+	var entry rueidis.ScanEntry
+	var err error
+	switch {
+	case match != "" && count > 0:
+		entry, err = client.Do(ctx, cmd.Match(match).Count(count).Build()).AsScanEntry()
+	case match != "":
+		entry, err = client.Do(ctx, cmd.Match(match).Build()).AsScanEntry()
+	case count > 0:
+		entry, err = client.Do(ctx, cmd.Count(count).Build()).AsScanEntry()
+	default:
+		entry, err = client.Do(ctx, cmd.Build()).AsScanEntry()
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entry.Elements, entry.Cursor, nil
+}
+
+// rueidisMasters returns the master nodes of a cluster ordered by address, so
+// that node indexes are stable between calls. rueidis also connects to
+// replicas, so the masters are looked up with CLUSTER SLOTS.
+func rueidisMasters(ctx context.Context, nodes map[string]rueidis.Client) ([]rueidis.Client, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("no cluster nodes connected")
+	}
+
+	addrs := make([]string, 0, len(nodes))
+	for addr := range nodes {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	node := nodes[addrs[0]]
+	slots, err := node.Do(ctx, node.B().ClusterSlots().Build()).ToArray()
+	if err != nil {
+		return nil, err
+	}
+
+	// A node that does not know its own address reports an empty host.
+	fallback, _, _ := net.SplitHostPort(addrs[0])
+
+	seen := make(map[string]bool, len(slots))
+	masters := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		fields, err := slot.ToArray()
+		if err != nil || len(fields) < 3 {
+			return nil, errors.New("unexpected CLUSTER SLOTS reply")
+		}
+
+		endpoint, err := fields[2].ToArray()
+		if err != nil || len(endpoint) < 2 {
+			return nil, errors.New("unexpected CLUSTER SLOTS reply")
+		}
+
+		host, _ := endpoint[0].ToString()
+		port, _ := endpoint[1].AsInt64()
+		switch host {
+		case "?":
+			continue
+		case "":
+			host = fallback
+		}
+
+		addr := net.JoinHostPort(host, strconv.FormatInt(port, 10))
+		if !seen[addr] {
+			seen[addr] = true
+			masters = append(masters, addr)
+		}
+	}
+	sort.Strings(masters)
+
+	clients := make([]rueidis.Client, 0, len(masters))
+	for _, addr := range masters {
+		client, ok := nodes[addr]
+		if !ok {
+			return nil, fmt.Errorf("master node %s is not connected", addr)
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+func (a *RueidisAdapter) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := a.Client.Do(ctx, a.Client.B().Pttl().Key(key).Build()).AsInt64()
+	if err != nil {
+		return 0, err
+	}
+
+	return pttl(ms)
+}
+
+// MGet reads keys in one round trip, grouping the keys of a cluster client by
+// slot. With client-side caching, cached keys are not read from redis.
+func (a *RueidisAdapter) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	var (
+		messages map[string]rueidis.RedisMessage
+		err      error
+	)
+	if a.cacheTTL > 0 {
+		messages, err = rueidis.MGetCache(a.Client, ctx, a.cacheTTL, keys)
+	} else {
+		messages, err = rueidis.MGet(a.Client, ctx, keys)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, len(keys))
+	for i, key := range keys {
+		msg, ok := messages[key]
+		if !ok || msg.IsNil() {
+			continue
+		}
+
+		if result[i], err = msg.AsBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// SetMany sets all entries in a single pipeline.
+func (a *RueidisAdapter) SetMany(ctx context.Context, entries ...redisstore.BatchEntry) error {
+	cmds := make(rueidis.Commands, 0, len(entries))
+	for _, entry := range entries {
+		cmds = append(cmds, a.set(entry.Key, entry.Value, entry.Expiration))
+	}
+
+	for _, result := range a.Client.DoMulti(ctx, cmds...) {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replace sets entry and deletes keys in a MULTI/EXEC transaction. With a
+// cluster client, the keys of different slots can not be part of the same
+// transaction, so the commands are sent in a pipeline without atomicity.
+func (a *RueidisAdapter) Replace(ctx context.Context, entry redisstore.BatchEntry, keys ...string) error {
+	cmds := make(rueidis.Commands, 0, len(keys)+1)
+	cmds = append(cmds, a.set(entry.Key, entry.Value, entry.Expiration))
+	for _, key := range keys {
+		cmds = append(cmds, a.Client.B().Del().Key(key).Build())
+	}
+
+	if a.cluster && !sameSlot(cmds) {
+		for _, result := range a.Client.DoMulti(ctx, cmds...) {
+			if err := result.Error(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return a.Client.Dedicated(func(client rueidis.DedicatedClient) error {
+		tx := make(rueidis.Commands, 0, len(cmds)+2)
+		tx = append(tx, client.B().Multi().Build())
+		tx = append(tx, cmds...)
+		tx = append(tx, client.B().Exec().Build())
+
+		results := client.DoMulti(ctx, tx...)
+		for _, result := range results {
+			if err := result.Error(); err != nil {
+				return err
+			}
+		}
+
+		// Errors of the queued commands are part of the EXEC reply.
+		replies, err := results[len(results)-1].ToArray()
+		if err != nil {
+			return err
+		}
+
+		for _, reply := range replies {
+			if err := reply.Error(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// sameSlot reports whether all commands access keys of the same slot.
+func sameSlot(cmds rueidis.Commands) bool {
+	for i := range cmds {
+		if cmds[i].Slot() != cmds[0].Slot() {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,405 @@
+// nolint: wrapcheck
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/joelrose/redisstore"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/redis/rueidis"
+)
+
+// WatchEventType describes why a session key disappeared.
+type WatchEventType int
+
+const (
+	// SessionExpired is reported when redis expired a session key.
+	SessionExpired WatchEventType = iota + 1
+	// SessionDeleted is reported when a session key was deleted.
+	SessionDeleted
+)
+
+// String returns the name of the event type.
+func (t WatchEventType) String() string {
+	switch t {
+	case SessionExpired:
+		return "expired"
+	case SessionDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("WatchEventType(%d)", int(t))
+	}
+}
+
+// WatchEvent is passed to the WatchFunc of a Watcher.
+type WatchEvent struct {
+	Type WatchEventType
+	// SessionID is the ID of the session.
+	SessionID string
+	// Key is the full redis key.
+	Key string
+}
+
+// WatchFunc is called for every expired or deleted session key.
+type WatchFunc func(event WatchEvent)
+
+// notifyKeyspaceEvents are the notify-keyspace-events flags the watcher
+// depends on: keyevent notifications (E) for generic commands (g) and expired
+// keys (x).
+const notifyKeyspaceEvents = "Egx"
+
+// ErrWatchNotSupported is returned by Watcher.Run if the server does not
+// support keyspace notifications.
+var ErrWatchNotSupported = errors.New("redisstore(watcher): server does not support keyspace notifications")
+
+// watchPatterns returns the keyevent channels supported by the server.
+func watchPatterns(caps redisstore.Capabilities) []string {
+	patterns := []string{"__keyevent@*__:expired"}
+	if caps.DelEvents {
+		patterns = append(patterns, "__keyevent@*__:del")
+	}
+
+	return patterns
+}
+
+// subscriber abstracts the pub/sub implementation of a redis client.
+type subscriber interface {
+	// subscribe blocks and passes every received message to handle until the
+	// context is canceled or the connection fails.
+	subscribe(ctx context.Context, patterns []string, handle func(channel, payload string)) error
+	// configure enables the given notify-keyspace-events flags.
+	configure(ctx context.Context, flags string) error
+}
+
+// WatcherOption configures a Watcher.
+type WatcherOption func(w *Watcher)
+
+// WithNotificationConfig makes the watcher enable the keyspace notifications
+// it needs via CONFIG SET before subscribing. Already enabled flags are kept.
+// Many managed redis offerings disable CONFIG, in which case notifications
+// have to be enabled through the provider instead.
+func WithNotificationConfig() WatcherOption {
+	return func(w *Watcher) {
+		w.configure = true
+	}
+}
+
+// WithReconnectBackoff sets the minimum and maximum delay between reconnect
+// attempts. The delay doubles after every failed attempt.
+// By default, the watcher waits between 100ms and 10s.
+func WithReconnectBackoff(min, max time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.minBackoff = min
+		w.maxBackoff = max
+	}
+}
+
+// WithWatchErrorHandler sets a function that is called with every error that
+// caused the watcher to reconnect.
+func WithWatchErrorHandler(fn func(err error)) WatcherOption {
+	return func(w *Watcher) {
+		w.onError = fn
+	}
+}
+
+// Watcher subscribes to redis keyspace notifications and reports expired and
+// deleted session keys of a store.
+//
+// Keyspace notifications are only delivered by the node that owns a key, so
+// in a redis cluster a watcher has to be started for every master node.
+type Watcher struct {
+	sub       subscriber
+	store     *redisstore.Store
+	fn        WatchFunc
+	configure bool
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onError    func(err error)
+}
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+func newWatcher(sub subscriber, store *redisstore.Store, fn WatchFunc, options ...WatcherOption) *Watcher {
+	w := &Watcher{
+		sub:        sub,
+		store:      store,
+		fn:         fn,
+		configure:  false,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		onError:    func(error) {},
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	return w
+}
+
+// NewWatcher returns a Watcher for the sessions of store.
+func (a *GoRedisAdapter) NewWatcher(store *redisstore.Store, fn WatchFunc, options ...WatcherOption) *Watcher {
+	return newWatcher(goRedisSubscriber{a.UniversalClient}, store, fn, options...)
+}
+
+// NewWatcher returns a Watcher for the sessions of store.
+func (a *RedigoAdapter) NewWatcher(store *redisstore.Store, fn WatchFunc, options ...WatcherOption) *Watcher {
+	return newWatcher(redigoSubscriber{a.Pool}, store, fn, options...)
+}
+
+// NewWatcher returns a Watcher for the sessions of store.
+func (a *RueidisAdapter) NewWatcher(store *redisstore.Store, fn WatchFunc, options ...WatcherOption) *Watcher {
+	return newWatcher(rueidisSubscriber{a.Client}, store, fn, options...)
+}
+
+// Run subscribes to the keyspace notifications and blocks until ctx is
+// canceled. Lost connections are re-established automatically.
+//
+// Run probes the server of the store first, see redisstore.Store.Probe, and
+// returns ErrWatchNotSupported instead of blocking if the server does not
+// publish the notifications, e.g. because notify-keyspace-events is not
+// configured. If the server does not publish notifications for deleted keys,
+// e.g. Dragonfly, only expired sessions are reported.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.configure {
+		if !w.store.Capabilities().Config {
+			return fmt.Errorf("redisstore(watcher): configuring keyspace notifications: server does not support CONFIG")
+		}
+
+		if err := w.sub.configure(ctx, notifyKeyspaceEvents); err != nil {
+			return fmt.Errorf("redisstore(watcher): configuring keyspace notifications: %v", err)
+		}
+	}
+
+	caps, err := w.store.Probe(ctx)
+	if err != nil && !errors.Is(err, redisstore.ErrProbeNotSupported) {
+		return fmt.Errorf("redisstore(watcher): %v", err)
+	}
+
+	if !caps.KeyspaceEvents {
+		return ErrWatchNotSupported
+	}
+
+	backoff := w.minBackoff
+	for {
+		start := time.Now()
+		err := w.sub.subscribe(ctx, watchPatterns(caps), w.handle)
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			w.onError(fmt.Errorf("redisstore(watcher): subscription failed: %v", err))
+		}
+
+		// Reset the backoff if the subscription was healthy for a while.
+		if time.Since(start) > w.maxBackoff {
+			backoff = w.minBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// handle turns a keyevent notification into a WatchEvent.
+func (w *Watcher) handle(channel, key string) {
+	event, ok := parseWatchEvent(w.store, channel, key)
+	if !ok {
+		return
+	}
+
+	w.fn(event)
+}
+
+func parseWatchEvent(store *redisstore.Store, channel, key string) (WatchEvent, bool) {
+	var typ WatchEventType
+
+	switch {
+	case strings.HasSuffix(channel, "__:expired"):
+		typ = SessionExpired
+	case strings.HasSuffix(channel, "__:del"):
+		typ = SessionDeleted
+	default:
+		return WatchEvent{}, false //nolint: exhaustruct
+	}
+
+	id, ok := store.SessionID(key)
+	if !ok {
+		return WatchEvent{}, false //nolint: exhaustruct
+	}
+
+	return WatchEvent{
+		Type:      typ,
+		SessionID: id,
+		Key:       key,
+	}, true
+}
+
+// mergeNotifyFlags adds the flags in want to the current notify-keyspace-events
+// value without removing any flag that is already enabled.
+func mergeNotifyFlags(current, want string) string {
+	merged := current
+	for _, flag := range want {
+		if !notifyFlagEnabled(merged, flag) {
+			merged += string(flag)
+		}
+	}
+
+	return merged
+}
+
+type goRedisSubscriber struct {
+	client goredis.UniversalClient
+}
+
+func (s goRedisSubscriber) subscribe(ctx context.Context, patterns []string, handle func(channel, payload string)) error {
+	pubsub := s.client.PSubscribe(ctx, patterns...)
+	defer pubsub.Close()
+
+	// Receive does not return when the context is canceled, closing the
+	// subscription unblocks it.
+	stop := closeOnDone(ctx, func() { _ = pubsub.Close() })
+	defer stop()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.(type) {
+		case *goredis.Message:
+			handle(m.Channel, m.Payload)
+		case *goredis.Subscription, *goredis.Pong:
+		default:
+			return fmt.Errorf("unexpected pub/sub message: %v", m)
+		}
+	}
+}
+
+func (s goRedisSubscriber) configure(ctx context.Context, flags string) error {
+	current, err := s.client.ConfigGet(ctx, "notify-keyspace-events").Result()
+	if err != nil {
+		return err
+	}
+
+	return s.client.ConfigSet(ctx, "notify-keyspace-events", mergeNotifyFlags(current["notify-keyspace-events"], flags)).Err()
+}
+
+type redigoSubscriber struct {
+	pool *redigo.Pool
+}
+
+func (s redigoSubscriber) subscribe(ctx context.Context, patterns []string, handle func(channel, payload string)) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+
+	psc := redigo.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	args := make([]interface{}, 0, len(patterns))
+	for _, pattern := range patterns {
+		args = append(args, pattern)
+	}
+
+	if err := psc.PSubscribe(args...); err != nil {
+		return err
+	}
+
+	for {
+		switch m := psc.ReceiveContext(ctx).(type) {
+		case redigo.Message:
+			handle(m.Channel, string(m.Data))
+		case redigo.Subscription, redigo.Pong:
+		case error:
+			return m
+		}
+	}
+}
+
+func (s redigoSubscriber) configure(ctx context.Context, flags string) error {
+	conn, err := s.pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("getting connection from pool: %v", err)
+	}
+	defer conn.Close()
+
+	values, err := redigo.Strings(redigo.DoContext(conn, ctx, "CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil {
+		return err
+	}
+
+	if len(values) != 2 {
+		return errors.New("unexpected CONFIG GET reply")
+	}
+
+	_, err = redigo.DoContext(conn, ctx, "CONFIG", "SET", "notify-keyspace-events", mergeNotifyFlags(values[1], flags))
+
+	return err
+}
+
+type rueidisSubscriber struct {
+	client rueidis.Client
+}
+
+func (s rueidisSubscriber) subscribe(ctx context.Context, patterns []string, handle func(channel, payload string)) error {
+	// Receive blocks until ctx is canceled or the connection fails.
+	return s.client.Receive(ctx, s.client.B().Psubscribe().Pattern(patterns...).Build(), func(msg rueidis.PubSubMessage) {
+		handle(msg.Channel, msg.Message)
+	})
+}
+
+func (s rueidisSubscriber) configure(ctx context.Context, flags string) error {
+	current, err := s.client.Do(ctx, s.client.B().ConfigGet().Parameter("notify-keyspace-events").Build()).AsStrMap()
+	if err != nil {
+		return err
+	}
+
+	return s.client.Do(ctx, s.client.B().ConfigSet().ParameterValue().
+		ParameterValue("notify-keyspace-events", mergeNotifyFlags(current["notify-keyspace-events"], flags)).Build()).Error()
+}
+
+// closeOnDone calls fn once ctx is done. The returned function stops waiting
+// for ctx and must be called once the caller returns.
+func closeOnDone(ctx context.Context, fn func()) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		select {
+		case <-ctx.Done():
+			fn()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
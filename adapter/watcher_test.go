@@ -0,0 +1,161 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/joelrose/redisstore"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWatchEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel string
+		key     string
+		want    WatchEvent
+		ok      bool
+	}{
+		{
+			name:    "expired",
+			channel: "__keyevent@0__:expired",
+			key:     "session_abc",
+			want:    WatchEvent{Type: SessionExpired, SessionID: "abc", Key: "session_abc"},
+			ok:      true,
+		},
+		{
+			name:    "deleted",
+			channel: "__keyevent@3__:del",
+			key:     "session_abc",
+			want:    WatchEvent{Type: SessionDeleted, SessionID: "abc", Key: "session_abc"},
+			ok:      true,
+		},
+		{name: "other prefix", channel: "__keyevent@0__:del", key: "cache_abc"},
+		{name: "prefix only", channel: "__keyevent@0__:del", key: "session_"},
+		{name: "other event", channel: "__keyevent@0__:set", key: "session_abc"},
+		{name: "auxiliary key", channel: "__keyevent@0__:del", key: "session_abc:flash"},
+	}
+
+	store := redisstore.New(nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseWatchEvent(store, tt.channel, tt.key)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNotifyFlagsEnabled(t *testing.T) {
+	assert.False(t, notifyFlagsEnabled("", "Ex"))
+	assert.False(t, notifyFlagsEnabled("Kx", "Ex"))
+	assert.False(t, notifyFlagsEnabled("Ex", "Eg"))
+	assert.True(t, notifyFlagsEnabled("KEx", "Ex"))
+	assert.True(t, notifyFlagsEnabled("AE", "Eg"))
+	assert.False(t, notifyFlagsEnabled("AK", "Ex"))
+}
+
+func TestWatcherNotSupported(t *testing.T) {
+	store := redisstore.New(nil, nil, redisstore.WithCapabilities(redisstore.Capabilities{KeyspaceEvents: false}))
+	watcher := newWatcher(nil, store, func(WatchEvent) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.ErrorIs(t, watcher.Run(ctx), ErrWatchNotSupported)
+}
+
+func TestMergeNotifyFlags(t *testing.T) {
+	assert.Equal(t, "Egx", mergeNotifyFlags("", "Egx"))
+	assert.Equal(t, "KExg", mergeNotifyFlags("KEx", "Egx"))
+	assert.Equal(t, "AK", mergeNotifyFlags("AK", "gx"))
+	assert.Equal(t, "AKE", mergeNotifyFlags("AK", "Egx"))
+}
+
+func TestWatcher_GoRedis(t *testing.T) {
+	client := goredis.NewClient(&goredis.Options{
+		Addr: "localhost:6379",
+	})
+	adapter := UseGoRedis(client)
+
+	Watch(t, adapter, func(store *redisstore.Store, fn WatchFunc) *Watcher {
+		return adapter.NewWatcher(store, fn, WithNotificationConfig())
+	})
+}
+
+func TestWatcher_Redigo(t *testing.T) {
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", "localhost:6379") // nolint: wrapcheck
+		},
+	}
+	adapter := UseRedigo(pool)
+
+	Watch(t, adapter, func(store *redisstore.Store, fn WatchFunc) *Watcher {
+		return adapter.NewWatcher(store, fn, WithNotificationConfig())
+	})
+}
+
+func TestWatcher_Rueidis(t *testing.T) {
+	adapter := UseRueidis(newRueidisClient(t, true))
+
+	Watch(t, adapter, func(store *redisstore.Store, fn WatchFunc) *Watcher {
+		return adapter.NewWatcher(store, fn, WithNotificationConfig())
+	})
+}
+
+func Watch(t *testing.T, client redisstore.Client, newWatcher func(*redisstore.Store, WatchFunc) *Watcher) {
+	t.Helper()
+
+	store := redisstore.New(client, [][]byte{[]byte("secret")}, redisstore.WithKeyPrefix("watch_"))
+
+	events := make(chan WatchEvent, 10)
+	watcher := newWatcher(store, func(event WatchEvent) {
+		events <- event
+	})
+
+	if err := watcher.sub.configure(context.Background(), notifyKeyspaceEvents); err != nil {
+		t.Skipf("server does not support keyspace notifications: %v", err)
+	}
+
+	caps := requireCapability(t, store, "keyspace notifications", func(caps redisstore.Capabilities) bool {
+		return caps.KeyspaceEvents
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	// Give the watcher time to subscribe.
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, client.Set(ctx, "watch_deleted", "value", time.Minute))
+	assert.NoError(t, client.Del(ctx, "watch_deleted"))
+	assert.NoError(t, client.Set(ctx, "other_key", "value", time.Minute))
+	assert.NoError(t, client.Del(ctx, "other_key"))
+	assert.NoError(t, client.Set(ctx, "watch_expired", "value", time.Second))
+
+	if caps.DelEvents {
+		select {
+		case event := <-events:
+			assert.Equal(t, WatchEvent{Type: SessionDeleted, SessionID: "deleted", Key: "watch_deleted"}, event)
+		case <-time.After(time.Second):
+			t.Fatal("no delete event received")
+		}
+	}
+
+	select {
+	case event := <-events:
+		assert.Equal(t, WatchEvent{Type: SessionExpired, SessionID: "expired", Key: "watch_expired"}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no expired event received")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
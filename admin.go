@@ -0,0 +1,286 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrScanNotSupported is returned by NewAdmin if the client of the store does
+// not implement KeyScanner or the server does not support SCAN.
+var ErrScanNotSupported = errors.New("redisstore: client does not support scanning keys")
+
+// SessionInfo describes a stored session.
+type SessionInfo struct {
+	// ID is the session ID.
+	ID string `json:"id"`
+	// Key is the redis key of the session.
+	Key string `json:"key"`
+	// TTL is the remaining time to live. It is zero if the client does not
+	// implement TTLReader or the server does not support PTTL and negative if
+	// the key does not expire.
+	TTL time.Duration `json:"ttl"`
+	// Size is the size of the serialized session in bytes.
+	Size int `json:"size"`
+	// Values are the decoded session values. Only set by Admin.Inspect.
+	Values map[interface{}]interface{} `json:"-"`
+}
+
+// Admin provides administrative access to all sessions of a store, e.g. to
+// list active sessions or purge them after a security incident.
+type Admin struct {
+	store         *Store
+	scanner       KeyScanner
+	batchSize     int
+	batchInterval time.Duration
+}
+
+// AdminOption configures an Admin.
+type AdminOption func(a *Admin)
+
+// WithBatchSize sets the number of keys requested per SCAN call and deleted
+// per batch. By default, 100 keys are processed per batch.
+func WithBatchSize(size int) AdminOption {
+	return func(a *Admin) {
+		a.batchSize = size
+	}
+}
+
+// WithBatchInterval sets the pause between two batches to limit the load on
+// redis. By default, batches are processed without a pause.
+func WithBatchInterval(interval time.Duration) AdminOption {
+	return func(a *Admin) {
+		a.batchInterval = interval
+	}
+}
+
+const defaultBatchSize = 100
+
+// NewAdmin returns an Admin for the sessions of store. The client of the store
+// must implement KeyScanner.
+func NewAdmin(store *Store, options ...AdminOption) (*Admin, error) {
+	scanner, ok := store.client.(KeyScanner)
+	if !ok || !store.Capabilities().Scan {
+		return nil, ErrScanNotSupported
+	}
+
+	a := &Admin{
+		store:         store,
+		scanner:       scanner,
+		batchSize:     defaultBatchSize,
+		batchInterval: 0,
+	}
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a, nil
+}
+
+// Walk calls fn for every stored session whose ID starts with idPrefix. An
+// empty idPrefix matches all sessions. Walking stops at the first error
+// returned by fn. Sessions that expire while walking are skipped.
+func (a *Admin) Walk(ctx context.Context, idPrefix string, fn func(info SessionInfo) error) error {
+	return a.scan(ctx, idPrefix, false, func(keys []string) error {
+		for _, key := range keys {
+			info, err := a.info(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := fn(info); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// List returns all stored sessions whose ID starts with idPrefix.
+func (a *Admin) List(ctx context.Context, idPrefix string) ([]SessionInfo, error) {
+	infos := make([]SessionInfo, 0)
+
+	err := a.Walk(ctx, idPrefix, func(info SessionInfo) error {
+		infos = append(infos, info)
+		return nil
+	})
+
+	return infos, err
+}
+
+// Inspect returns a single session including its decoded values. It returns
+// ErrNotFound if the session does not exist.
+func (a *Admin) Inspect(ctx context.Context, id string) (*SessionInfo, error) {
+	key := a.store.SessionKey(id)
+
+	val, err := a.store.client.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("redisstore(admin): getting session: %w", err)
+	}
+
+	session := sessions.NewSession(a.store, "")
+	session.ID = id
+	if err := a.store.serializer.Deserialize(val, session); err != nil {
+		return nil, fmt.Errorf("redisstore(admin): deserializing session: %v", err)
+	}
+
+	ttl, err := a.ttl(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SessionInfo{
+		ID:     id,
+		Key:    key,
+		TTL:    ttl,
+		Size:   len(val),
+		Values: session.Values,
+	}, nil
+}
+
+// Delete removes a single session and its auxiliary keys. It returns
+// ErrNotFound if the session does not exist; auxiliary keys left behind by
+// the session are deleted anyway.
+func (a *Admin) Delete(ctx context.Context, id string) error {
+	_, err := a.store.client.Get(ctx, a.store.SessionKey(id))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("redisstore(admin): getting session: %v", err)
+	}
+	found := err == nil
+
+	if err := a.store.deleteID(ctx, id); err != nil {
+		return fmt.Errorf("redisstore(admin): %v", err)
+	}
+
+	if !found {
+		return fmt.Errorf("redisstore(admin): session %q: %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Purge deletes all sessions whose ID starts with idPrefix, including their
+// auxiliary keys such as flash messages, and returns the number of deleted
+// sessions. An empty idPrefix deletes all sessions of the store. The keys of
+// every batch are deleted in a single round trip if the client implements
+// MultiDeleter.
+func (a *Admin) Purge(ctx context.Context, idPrefix string) (int, error) {
+	deleted := 0
+
+	err := a.scan(ctx, idPrefix, true, func(keys []string) error {
+		if err := a.store.del(ctx, keys...); err != nil {
+			return fmt.Errorf("redisstore(admin): deleting sessions: %v", err)
+		}
+
+		for _, key := range keys {
+			if _, ok := a.store.SessionID(key); ok {
+				deleted++
+			}
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// scan calls fn with every batch of session keys whose ID starts with
+// idPrefix, pausing between batches. With aux, the batches also contain the
+// auxiliary keys of the sessions.
+func (a *Admin) scan(ctx context.Context, idPrefix string, aux bool, fn func(keys []string) error) error {
+	match := a.store.sessionKeyPattern(idPrefix, aux)
+
+	var cursor uint64
+	for first := true; first || cursor != 0; first = false {
+		if !first && a.batchInterval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(a.batchInterval):
+			}
+		}
+
+		page, next, err := a.scanner.Scan(ctx, cursor, match, int64(a.batchSize))
+		if err != nil {
+			return fmt.Errorf("redisstore(admin): scanning sessions: %v", err)
+		}
+		cursor = next
+
+		// The pattern may also match the auxiliary keys of sessions.
+		keys := make([]string, 0, len(page))
+		for _, key := range page {
+			if _, ok := a.store.SessionID(key); ok {
+				keys = append(keys, key)
+			} else if _, ok := a.store.auxSessionID(key); ok && aux {
+				keys = append(keys, key)
+			}
+		}
+
+		if err := fn(keys); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// info reads size and ttl of a session key.
+func (a *Admin) info(ctx context.Context, key string) (SessionInfo, error) {
+	val, err := a.store.client.Get(ctx, key)
+	if err != nil {
+		return SessionInfo{}, fmt.Errorf("redisstore(admin): getting session: %w", err) //nolint: exhaustruct
+	}
+
+	ttl, err := a.ttl(ctx, key)
+	if err != nil {
+		return SessionInfo{}, err //nolint: exhaustruct
+	}
+
+	id, _ := a.store.SessionID(key)
+
+	return SessionInfo{
+		ID:     id,
+		Key:    key,
+		TTL:    ttl,
+		Size:   len(val),
+		Values: nil,
+	}, nil
+}
+
+func (a *Admin) ttl(ctx context.Context, key string) (time.Duration, error) {
+	reader, ok := a.store.client.(TTLReader)
+	if !ok || !a.store.Capabilities().TTL {
+		return 0, nil
+	}
+
+	ttl, err := reader.TTL(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("redisstore(admin): getting ttl: %w", err)
+	}
+
+	return ttl, nil
+}
+
+// escapeGlob escapes the characters with a special meaning in redis glob
+// patterns.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,251 @@
+package redisstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/gorilla/sessions"
+	"github.com/joelrose/redisstore/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// scanClient is a map based Client implementing KeyScanner and TTLReader.
+// Scan returns one key per page to exercise the cursor handling.
+type scanClient struct {
+	data     map[string][]byte
+	snapshot []string
+	scans    int
+}
+
+func (c *scanClient) Get(_ context.Context, key string) ([]byte, error) {
+	val, ok := c.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return val, nil
+}
+
+func (c *scanClient) Set(_ context.Context, key string, value interface{}, _ time.Duration) error {
+	c.data[key] = value.([]byte)
+	return nil
+}
+
+func (c *scanClient) Del(_ context.Context, key string) error {
+	delete(c.data, key)
+	return nil
+}
+
+func (c *scanClient) Scan(_ context.Context, cursor uint64, match string, _ int64) ([]string, uint64, error) {
+	c.scans++
+
+	// Like redis, return every key that exists during the whole iteration
+	// even if other keys are deleted in between.
+	if cursor == 0 {
+		c.snapshot = c.snapshot[:0]
+		for key := range c.data {
+			if ok, _ := path.Match(match, key); ok {
+				c.snapshot = append(c.snapshot, key)
+			}
+		}
+		sort.Strings(c.snapshot)
+	}
+	keys := c.snapshot
+
+	if int(cursor) >= len(keys) {
+		return nil, 0, nil
+	}
+
+	page := keys[cursor : cursor+1]
+
+	next := cursor + 1
+	if int(next) == len(keys) {
+		next = 0
+	}
+
+	return page, next, nil
+}
+
+func (c *scanClient) TTL(_ context.Context, key string) (time.Duration, error) {
+	if _, ok := c.data[key]; !ok {
+		return 0, ErrNotFound
+	}
+
+	return time.Minute, nil
+}
+
+func newAdminStore(t *testing.T) (*Store, *scanClient) {
+	t.Helper()
+
+	client := &scanClient{data: map[string][]byte{
+		"session_a1":       []byte(`{"user":"a"}`),
+		"session_a2":       []byte(`{"user":"b"}`),
+		"session_b1":       []byte(`{"user":"c"}`),
+		"session_a1:flash": []byte(`{}`),
+		"other_a1":         []byte(`{}`),
+		"session*_a1":      []byte(`{}`),
+	}}
+
+	return New(client, nil, WithSerializer(JSONSerializer{})), client
+}
+
+func TestNewAdmin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	_, err := NewAdmin(New(mocks.NewMockRedisClient(mockCtrl), nil))
+	assert.ErrorIs(t, err, ErrScanNotSupported)
+}
+
+func TestAdminList(t *testing.T) {
+	store, client := newAdminStore(t)
+
+	admin, err := NewAdmin(store, WithBatchSize(1), WithBatchInterval(time.Millisecond))
+	assert.NoError(t, err)
+
+	infos, err := admin.List(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []SessionInfo{
+		{ID: "a1", Key: "session_a1", TTL: time.Minute, Size: 12},
+		{ID: "a2", Key: "session_a2", TTL: time.Minute, Size: 12},
+		{ID: "b1", Key: "session_b1", TTL: time.Minute, Size: 12},
+	}, infos)
+	assert.Equal(t, 4, client.scans)
+
+	infos, err = admin.List(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+}
+
+func TestAdminInspect(t *testing.T) {
+	store, _ := newAdminStore(t)
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+
+	info, err := admin.Inspect(context.Background(), "a2")
+	assert.NoError(t, err)
+	assert.Equal(t, &SessionInfo{
+		ID:     "a2",
+		Key:    "session_a2",
+		TTL:    time.Minute,
+		Size:   12,
+		Values: map[interface{}]interface{}{"user": "b"},
+	}, info)
+
+	_, err = admin.Inspect(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestAdminDelete(t *testing.T) {
+	store, client := newAdminStore(t)
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	session := sessions.NewSession(store, "session")
+	session.ID = "a1"
+	session.Options = &sessions.Options{MaxAge: 60}
+	assert.NoError(t, store.AddFlash(req, httptest.NewRecorder(), session, "info", "saved"))
+
+	assert.NoError(t, admin.Delete(context.Background(), "a1"))
+	assert.NotContains(t, client.data, "session_a1")
+	assert.NotContains(t, client.data, "session_a1:flash:info")
+	assert.NotContains(t, client.data, "session_a1:aux")
+
+	assert.ErrorIs(t, admin.Delete(context.Background(), "a1"), ErrNotFound)
+}
+
+// delManyClient is a scanClient implementing MultiDeleter that records the
+// keys of every call.
+type delManyClient struct {
+	*scanClient
+	calls [][]string
+}
+
+func (c *delManyClient) DelMany(_ context.Context, keys ...string) error {
+	c.calls = append(c.calls, keys)
+
+	for _, key := range keys {
+		delete(c.data, key)
+	}
+
+	return nil
+}
+
+func TestAdminPurge(t *testing.T) {
+	store, client := newAdminStore(t)
+
+	admin, err := NewAdmin(store, WithBatchSize(1))
+	assert.NoError(t, err)
+
+	// Auxiliary keys are deleted with their session.
+	deleted, err := admin.Purge(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Contains(t, client.data, "session_b1")
+	assert.NotContains(t, client.data, "session_a1:flash")
+
+	deleted, err = admin.Purge(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, map[string][]byte{
+		"other_a1":    []byte(`{}`),
+		"session*_a1": []byte(`{}`),
+	}, client.data)
+}
+
+func TestAdminPurgeBatches(t *testing.T) {
+	_, scan := newAdminStore(t)
+	client := &delManyClient{scanClient: scan}
+	store := New(client, nil, WithSerializer(JSONSerializer{}))
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+
+	// The scan client returns one key per page.
+	deleted, err := admin.Purge(context.Background(), "a1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, [][]string{{"session_a1"}, {"session_a1:flash"}}, client.calls)
+}
+
+func TestAdminHashTags(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{
+		"session_{a1}":       []byte(`{"user":"a"}`),
+		"session_{b1}":       []byte(`{"user":"b"}`),
+		"session_{a1}:flash": []byte(`{}`),
+		"session_a2":         []byte(`{}`),
+	}}
+	store := New(client, nil, WithSerializer(JSONSerializer{}), WithClusterHashTags())
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+
+	infos, err := admin.List(context.Background(), "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []SessionInfo{
+		{ID: "a1", Key: "session_{a1}", TTL: time.Minute, Size: 12},
+	}, infos)
+
+	info, err := admin.Inspect(context.Background(), "b1")
+	assert.NoError(t, err)
+	assert.Equal(t, "session_{b1}", info.Key)
+
+	deleted, err := admin.Purge(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, map[string][]byte{"session_a2": []byte(`{}`)}, client.data)
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, `session_`, escapeGlob("session_"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\f`, escapeGlob(`a*b?c[d]e\f`))
+}
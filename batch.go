@@ -0,0 +1,250 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// BatchClient is an optional Client capability to read and write several keys
+// in a single round trip.
+type BatchClient interface {
+	// MGet returns the values of keys in the same order. The value of a
+	// missing key is nil.
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+	// SetMany sets all entries in a single pipeline.
+	SetMany(ctx context.Context, entries ...BatchEntry) error
+}
+
+// BatchEntry is a value written by BatchClient.SetMany.
+type BatchEntry struct {
+	Key        string
+	Value      interface{}
+	Expiration time.Duration
+}
+
+// batchResult is a session value read ahead by LoadMany.
+type batchResult struct {
+	val []byte
+	err error
+}
+
+// LoadMany returns the sessions for the given names after adding them to the
+// registry of the request, reading all of them from redis in one round trip
+// if the client implements BatchClient. Later calls of Get return the loaded
+// sessions. Like Get, it returns a session for every name and the first error
+// that occurred.
+func (s *Store) LoadMany(r *http.Request, names ...string) (map[string]*sessions.Session, error) {
+	registry := sessions.GetRegistry(r)
+
+	if batch, ok := s.client.(BatchClient); ok {
+		// The registry calls New with the request it was created for, which
+		// differs from r if r was derived with WithContext, so the results
+		// are stored per registry.
+		results := s.readAhead(r, batch, names)
+		s.batches.Store(registry, results)
+		defer s.batches.Delete(registry)
+	}
+
+	var firstErr error
+	loaded := make(map[string]*sessions.Session, len(names))
+	for _, name := range names {
+		session, err := registry.Get(s, name)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if session != nil {
+			loaded[name] = session
+		}
+	}
+
+	return loaded, firstErr //nolint: wrapcheck
+}
+
+// readAhead reads the sessions referenced by the cookies of names with a
+// single MGET.
+func (s *Store) readAhead(r *http.Request, batch BatchClient, names []string) map[string]batchResult {
+	results := make(map[string]batchResult, len(names))
+
+	keyNames := make([]string, 0, len(names))
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		value, _, ok := s.readID(r, name)
+		if !ok {
+			continue
+		}
+
+		id, err := s.codec().Decode(name, value)
+		if err != nil {
+			continue
+		}
+
+		keyNames = append(keyNames, name)
+		keys = append(keys, s.SessionKey(id))
+	}
+
+	if len(keys) == 0 {
+		return results
+	}
+
+	values, err := batch.MGet(r.Context(), keys...)
+	if err == nil && len(values) != len(keys) {
+		err = fmt.Errorf("got %d values for %d keys", len(values), len(keys))
+	}
+
+	for i, name := range keyNames {
+		switch {
+		case err != nil:
+			results[name] = batchResult{val: nil, err: err}
+		case values[i] == nil:
+			results[name] = batchResult{val: nil, err: ErrNotFound}
+		default:
+			results[name] = batchResult{val: values[i], err: nil}
+		}
+	}
+
+	return results
+}
+
+// loadRequest reads the session for a request, using the values read ahead by
+// LoadMany if there are any.
+func (s *Store) loadRequest(r *http.Request, session *sessions.Session) error {
+	if s.readingAhead() {
+		if results, ok := s.batches.Load(sessions.GetRegistry(r)); ok {
+			if result, ok := results.(map[string]batchResult)[session.Name()]; ok {
+				if result.err != nil {
+					return fmt.Errorf("getting session: %v", result.err)
+				}
+
+				return s.deserialize(result.val, session)
+			}
+		}
+	}
+
+	return s.load(r.Context(), session)
+}
+
+// readingAhead reports whether any LoadMany call is in progress. It keeps New
+// from adding a registry to requests that are not loaded with LoadMany.
+func (s *Store) readingAhead() bool {
+	pending := false
+	s.batches.Range(func(_, _ interface{}) bool {
+		pending = true
+
+		return false
+	})
+
+	return pending
+}
+
+// SaveMany saves several sessions of a request, writing all of them to redis
+// in one round trip if the client implements BatchClient. Sessions with
+// Options.MaxAge <= 0 are deleted like in Save.
+func (s *Store) SaveMany(r *http.Request, w http.ResponseWriter, list ...*sessions.Session) error {
+	batch, ok := s.client.(BatchClient)
+	if !ok {
+		for _, session := range list {
+			if err := s.Save(r, w, session); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	pending := make([]*sessions.Session, 0, len(list))
+	entries := make([]BatchEntry, 0, len(list))
+	for _, session := range list {
+		capImpersonation(session)
+
+		if session.Options.MaxAge <= 0 {
+			if err := s.Save(r, w, session); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if session.ID == "" {
+			session.ID = s.keyGen()
+		}
+
+		s.bind(r, session)
+
+		b, err := s.serializer.Serialize(session)
+		if err != nil {
+			return fmt.Errorf("redisstore(save): serializing session: %v", err)
+		}
+
+		pending = append(pending, session)
+		entries = append(entries, BatchEntry{
+			Key:        s.SessionKey(session.ID),
+			Value:      b,
+			Expiration: time.Duration(session.Options.MaxAge) * time.Second,
+		})
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var previous []*sessions.Session
+	if len(s.eventHandlers) > 0 {
+		previous = s.previousMany(r.Context(), batch, pending, entries)
+	}
+
+	if err := batch.SetMany(r.Context(), entries...); err != nil {
+		return fmt.Errorf("redisstore(save): saving sessions: %v", err)
+	}
+
+	for i, session := range pending {
+		var prev *sessions.Session
+		if previous != nil {
+			prev = previous[i]
+		}
+
+		if err := s.saved(r, w, session, prev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// previousMany reads the currently stored state of sessions with a single
+// MGET. Sessions that do not exist yet are nil.
+func (s *Store) previousMany(
+	ctx context.Context,
+	batch BatchClient,
+	list []*sessions.Session,
+	entries []BatchEntry,
+) []*sessions.Session {
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+
+	previous := make([]*sessions.Session, len(list))
+
+	values, err := batch.MGet(ctx, keys...)
+	if err != nil || len(values) != len(list) {
+		return previous
+	}
+
+	for i, session := range list {
+		if values[i] == nil {
+			continue
+		}
+
+		prev := sessions.NewSession(s, session.Name())
+		prev.ID = session.ID
+		if err := s.deserialize(values[i], prev); err == nil {
+			previous[i] = prev
+		}
+	}
+
+	return previous
+}
@@ -0,0 +1,171 @@
+package redisstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchClient is a scanClient implementing BatchClient that counts the calls
+// of every operation.
+type batchClient struct {
+	scanClient
+	gets, mgets, sets, setManys int
+}
+
+func (c *batchClient) Get(ctx context.Context, key string) ([]byte, error) {
+	c.gets++
+	return c.scanClient.Get(ctx, key)
+}
+
+func (c *batchClient) MGet(_ context.Context, keys ...string) ([][]byte, error) {
+	c.mgets++
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = c.data[key]
+	}
+
+	return values, nil
+}
+
+func (c *batchClient) SetMany(ctx context.Context, entries ...BatchEntry) error {
+	c.setManys++
+
+	for _, entry := range entries {
+		c.data[entry.Key] = entry.Value.([]byte)
+	}
+
+	return nil
+}
+
+func TestStoreSaveMany(t *testing.T) {
+	client := &batchClient{scanClient: scanClient{data: map[string][]byte{}}}
+	rec := &recorder{}
+	store := New(client, [][]byte{[]byte("key")}, WithSerializer(JSONSerializer{}), WithEventHandler(rec))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	auth, _ := store.New(req, "auth")
+	auth.Values["user"] = "a"
+	cart, _ := store.New(req, "cart")
+	cart.Values["items"] = "1"
+
+	assert.NoError(t, store.SaveMany(req, res, auth, cart))
+	assert.Equal(t, 1, client.setManys)
+	assert.Len(t, client.data, 2)
+	assert.Len(t, res.Result().Cookies(), 2)
+	assert.Equal(t, []EventType{SessionCreated, SessionCreated}, rec.types())
+
+	cart.Values["items"] = "2"
+	assert.NoError(t, store.SaveMany(req, httptest.NewRecorder(), auth, cart))
+	assert.Equal(t, 2, client.setManys)
+	assert.Equal(t, 0, client.gets)
+	assert.Equal(t, []EventType{SessionCreated, SessionCreated, SessionSaved, SessionSaved}, rec.types())
+	assert.Equal(t, []string{"items"}, rec.events[3].Keys)
+}
+
+func TestStoreLoadMany(t *testing.T) {
+	client := &batchClient{scanClient: scanClient{data: map[string][]byte{}}}
+	store := New(client, [][]byte{[]byte("key")}, WithSerializer(JSONSerializer{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	auth, _ := store.New(req, "auth")
+	auth.Values["user"] = "a"
+	cart, _ := store.New(req, "cart")
+	cart.Values["items"] = "1"
+	expired, _ := store.New(req, "expired")
+	assert.NoError(t, store.SaveMany(req, res, auth, cart, expired))
+	delete(client.data, store.SessionKey(expired.ID))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+	loaded, err := store.LoadMany(req, "auth", "cart", "expired", "missing")
+	assert.NoError(t, err)
+	assert.Len(t, loaded, 4)
+	assert.Equal(t, 1, client.mgets)
+	assert.Equal(t, 0, client.gets)
+
+	assert.False(t, loaded["auth"].IsNew)
+	assert.Equal(t, "a", loaded["auth"].Values["user"])
+	assert.False(t, loaded["cart"].IsNew)
+	assert.Equal(t, "1", loaded["cart"].Values["items"])
+	assert.True(t, loaded["expired"].IsNew)
+	assert.True(t, loaded["missing"].IsNew)
+
+	// The sessions are registered for the request.
+	session, err := store.Get(req, "cart")
+	assert.NoError(t, err)
+	assert.Same(t, loaded["cart"], session)
+
+	// Without LoadMany, sessions are read one by one.
+	other, err := store.New(req, "auth")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", other.Values["user"])
+	assert.Equal(t, 1, client.gets)
+}
+
+func TestStoreLoadMany_NestedMiddleware(t *testing.T) {
+	client := &batchClient{scanClient: scanClient{data: map[string][]byte{}}}
+	store := New(client, [][]byte{[]byte("key")}, WithSerializer(JSONSerializer{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	auth, _ := store.New(req, "auth")
+	auth.Values["user"] = "a"
+	cart, _ := store.New(req, "cart")
+	cart.Values["items"] = "1"
+	assert.NoError(t, store.SaveMany(req, res, auth, cart))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+	var loaded map[string]*sessions.Session
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		loaded, err = store.LoadMany(r, "auth", "cart")
+		assert.NoError(t, err)
+	})
+	outer := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// An outer middleware creates the registry before the request is
+		// derived with WithContext.
+		sessions.GetRegistry(r)
+		inner.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), struct{}{}, "value")))
+	})
+	outer.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, client.mgets)
+	assert.Equal(t, 0, client.gets)
+	assert.Equal(t, "a", loaded["auth"].Values["user"])
+	assert.Equal(t, "1", loaded["cart"].Values["items"])
+}
+
+func TestStoreLoadMany_NoBatchClient(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")}, WithSerializer(JSONSerializer{}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	auth, _ := store.New(req, "auth")
+	auth.Values["user"] = "a"
+	assert.NoError(t, store.SaveMany(req, res, auth))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+	loaded, err := store.LoadMany(req, "auth", "cart")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", loaded["auth"].Values["user"])
+	assert.True(t, loaded["cart"].IsNew)
+}
@@ -0,0 +1,248 @@
+package redisstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrBindingMismatch is the error of SessionRejected events for sessions used
+// by a client other than the one that created them.
+var ErrBindingMismatch = errors.New("redisstore: session binding mismatch")
+
+// BindingPolicy decides what happens to a session whose binding does not match
+// the request.
+type BindingPolicy int
+
+const (
+	// BindingReject ignores the session and returns a new one. The stored
+	// session is kept for the client it belongs to.
+	BindingReject BindingPolicy = iota
+	// BindingRegenerate deletes the stored session and returns a new, empty
+	// session bound to the request, so that the cookie presented with the
+	// request can not be used anymore by either client. The values of the
+	// stored session are not passed to the mismatching client.
+	BindingRegenerate
+	// BindingFlag keeps the session and only calls Binding.OnMismatch.
+	BindingFlag
+)
+
+// BindingAttribute is a property of the client a session is bound to.
+type BindingAttribute struct {
+	// Name identifies the attribute in the stored binding. It must be unique.
+	Name string
+	// Value returns the attribute of a request. It is stored as a keyed hash
+	// only.
+	Value func(r *http.Request) string
+	// Soft attributes never trigger the policy. A mismatch is reported to
+	// Binding.OnMismatch and the session is bound to the new value, which
+	// tolerates changes like the IP address of a mobile client.
+	Soft bool
+}
+
+// Binding configures how sessions are bound to the client that created them.
+type Binding struct {
+	// Key is the HMAC key used to hash attribute values. Without a key, values
+	// are hashed with plain SHA-256, which does not protect attributes with few
+	// possible values, such as IP prefixes, from being brute forced.
+	Key []byte
+	// Attributes are compared on every Store.New.
+	Attributes []BindingAttribute
+	// Policy is applied when an attribute that is not soft does not match. By
+	// default, the session is rejected.
+	Policy BindingPolicy
+	// OnMismatch is called with the names of all mismatching attributes, before
+	// the policy is applied. It may be nil.
+	OnMismatch func(r *http.Request, session *sessions.Session, attributes []string)
+}
+
+// WithSessionBinding binds sessions to attributes of the client that created
+// them, which detects session cookies that are used by another client. The
+// hashed attributes are stored in the session values under a reserved key when
+// a session is saved for the first time.
+//
+// Sessions created before the binding was enabled are bound on their next
+// Save.
+func WithSessionBinding(binding Binding) Options {
+	return func(s *Store) {
+		s.binding = &binding
+	}
+}
+
+// bindingValueKey is the session value that stores the binding of a session.
+const bindingValueKey = "_redisstore_binding"
+
+// bindingHashSize is the number of bytes of the attribute hashes that are
+// stored.
+const bindingHashSize = 16
+
+// UserAgent binds sessions to the User-Agent header.
+func UserAgent() BindingAttribute {
+	return BindingAttribute{
+		Name:  "ua",
+		Value: func(r *http.Request) string { return r.UserAgent() },
+		Soft:  false,
+	}
+}
+
+// IPPrefix binds sessions to the network of the client address, e.g. /24 for
+// IPv4 and /48 for IPv6 addresses. It reads http.Request.RemoteAddr, which
+// must be set to the client address behind proxies.
+func IPPrefix(ipv4Bits, ipv6Bits int) BindingAttribute {
+	return BindingAttribute{
+		Name: "ip",
+		Value: func(r *http.Request) string {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return ""
+			}
+
+			addr = addr.Unmap()
+			bits := ipv6Bits
+			if addr.Is4() {
+				bits = ipv4Bits
+			}
+
+			prefix, err := addr.Prefix(bits)
+			if err != nil {
+				return ""
+			}
+
+			return prefix.String()
+		},
+		Soft: false,
+	}
+}
+
+// TLSClientCertificate binds sessions to the TLS client certificate of the
+// connection. Requests without a client certificate have an empty value.
+func TLSClientCertificate() BindingAttribute {
+	return BindingAttribute{
+		Name: "tls",
+		Value: func(r *http.Request) string {
+			if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+				return ""
+			}
+
+			return string(r.TLS.PeerCertificates[0].Raw)
+		},
+		Soft: false,
+	}
+}
+
+// fingerprint returns the hashed attributes of a request.
+func (b *Binding) fingerprint(r *http.Request) url.Values {
+	values := make(url.Values, len(b.Attributes))
+	for _, attr := range b.Attributes {
+		values.Set(attr.Name, b.hash(attr.Value(r)))
+	}
+
+	return values
+}
+
+func (b *Binding) hash(value string) string {
+	var sum []byte
+	if len(b.Key) > 0 {
+		mac := hmac.New(sha256.New, b.Key)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		hash := sha256.Sum256([]byte(value))
+		sum = hash[:]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sum[:bindingHashSize])
+}
+
+// bind records the binding of a session that does not have one yet.
+func (s *Store) bind(r *http.Request, session *sessions.Session) {
+	if s.binding == nil {
+		return
+	}
+
+	if _, ok := session.Values[bindingValueKey].(string); ok {
+		return
+	}
+
+	session.Values[bindingValueKey] = s.binding.fingerprint(r).Encode()
+}
+
+// checkBinding compares the binding of a loaded session with the request and
+// applies the policy. It reports whether the loaded session is used.
+func (s *Store) checkBinding(r *http.Request, session *sessions.Session) bool {
+	stored, ok := session.Values[bindingValueKey].(string)
+	if !ok {
+		return true
+	}
+
+	recorded, err := url.ParseQuery(stored)
+	if err != nil {
+		// Bind the session again on the next Save.
+		delete(session.Values, bindingValueKey)
+		return true
+	}
+
+	current := s.binding.fingerprint(r)
+
+	var strict, soft []string
+	for _, attr := range s.binding.Attributes {
+		want := recorded.Get(attr.Name)
+		if want == "" {
+			// The attribute was added after the session was bound.
+			recorded.Set(attr.Name, current.Get(attr.Name))
+			continue
+		}
+
+		if hmac.Equal([]byte(want), []byte(current.Get(attr.Name))) {
+			continue
+		}
+
+		if attr.Soft {
+			soft = append(soft, attr.Name)
+			recorded.Set(attr.Name, current.Get(attr.Name))
+		} else {
+			strict = append(strict, attr.Name)
+		}
+	}
+
+	if len(strict) > 0 || len(soft) > 0 {
+		if s.binding.OnMismatch != nil {
+			s.binding.OnMismatch(r, session, append(strict, soft...))
+		}
+	}
+
+	if len(strict) == 0 || s.binding.Policy == BindingFlag {
+		session.Values[bindingValueKey] = recorded.Encode()
+		return true
+	}
+
+	s.emit(SessionRejected, r, session, nil, fmt.Errorf("%w: %s", ErrBindingMismatch, strings.Join(strict, ", ")))
+
+	if s.binding.Policy == BindingRegenerate {
+		// A failed delete leaves the old session to its expiration.
+		if err := s.delete(r.Context(), session); err == nil {
+			s.emit(SessionDeleted, r, session, nil, nil)
+		}
+		session.Values = map[interface{}]interface{}{bindingValueKey: current.Encode()}
+	} else {
+		session.Values = make(map[interface{}]interface{})
+	}
+
+	session.ID = ""
+
+	return false
+}
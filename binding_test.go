@@ -0,0 +1,181 @@
+package redisstore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingAttributes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "browser")
+	assert.Equal(t, "browser", UserAgent().Value(req))
+
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.10:1234", "192.0.2.0/24"},
+		{"[::ffff:192.0.2.10]:1234", "192.0.2.0/24"},
+		{"[2001:db8:1:2::1]:1234", "2001:db8:1::/48"},
+		{"192.0.2.10", "192.0.2.0/24"},
+		{"invalid", ""},
+	}
+	for _, tt := range tests {
+		req.RemoteAddr = tt.remoteAddr
+		assert.Equal(t, tt.want, IPPrefix(24, 48).Value(req), tt.remoteAddr)
+	}
+
+	assert.Equal(t, "", TLSClientCertificate().Value(req))
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Raw: []byte("cert")}}} //nolint: exhaustruct
+	assert.Equal(t, "cert", TLSClientCertificate().Value(req))
+}
+
+func TestSessionBinding(t *testing.T) {
+	newRequest := func(userAgent, remoteAddr string, cookies ...*http.Cookie) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("User-Agent", userAgent)
+		req.RemoteAddr = remoteAddr
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+
+		return req
+	}
+
+	setup := func(t *testing.T, policy BindingPolicy) (*Store, *scanClient, *recorder, *[]string, *http.Cookie) {
+		t.Helper()
+
+		client := &scanClient{data: map[string][]byte{}}
+		rec := &recorder{}
+		var mismatches []string
+		store := New(client, [][]byte{[]byte("key")}, WithEventHandler(rec), WithSessionBinding(Binding{
+			Key:        []byte("binding-key"),
+			Attributes: []BindingAttribute{UserAgent(), {Name: "ip", Value: IPPrefix(24, 48).Value, Soft: true}},
+			Policy:     policy,
+			OnMismatch: func(_ *http.Request, _ *sessions.Session, attributes []string) {
+				mismatches = append(mismatches, attributes...)
+			},
+		}))
+
+		req := newRequest("browser", "192.0.2.10:1234")
+		res := httptest.NewRecorder()
+		session, _ := store.New(req, "session")
+		session.Values["user"] = "a"
+		assert.NoError(t, store.Save(req, res, session))
+
+		stored, ok := session.Values[bindingValueKey].(string)
+		assert.True(t, ok)
+		assert.NotContains(t, stored, "browser")
+		assert.NotContains(t, stored, "192.0.2")
+
+		return store, client, rec, &mismatches, res.Result().Cookies()[0]
+	}
+
+	t.Run("match", func(t *testing.T) {
+		store, _, _, mismatches, cookie := setup(t, BindingReject)
+
+		session, err := store.New(newRequest("browser", "192.0.2.99:1234", cookie), "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.Equal(t, "a", session.Values["user"])
+		assert.Empty(t, *mismatches)
+	})
+
+	t.Run("soft mismatch", func(t *testing.T) {
+		store, _, _, mismatches, cookie := setup(t, BindingReject)
+
+		req := newRequest("browser", "198.51.100.1:1234", cookie)
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.Equal(t, []string{"ip"}, *mismatches)
+
+		// The session is bound to the new network on save.
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+		_, err = store.New(newRequest("browser", "198.51.100.2:1234", cookie), "session")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"ip"}, *mismatches)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		store, client, rec, mismatches, cookie := setup(t, BindingReject)
+
+		session, err := store.New(newRequest("other", "198.51.100.1:1234", cookie), "session")
+		assert.NoError(t, err)
+		assert.True(t, session.IsNew)
+		assert.Empty(t, session.ID)
+		assert.Empty(t, session.Values)
+		assert.Equal(t, []string{"ua", "ip"}, *mismatches)
+		assert.Len(t, client.data, 1)
+
+		last := rec.events[len(rec.events)-1]
+		assert.Equal(t, SessionRejected, last.Type)
+		assert.True(t, errors.Is(last.Err, ErrBindingMismatch))
+	})
+
+	t.Run("regenerate", func(t *testing.T) {
+		store, client, rec, _, cookie := setup(t, BindingRegenerate)
+
+		req := newRequest("other", "192.0.2.10:1234", cookie)
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.True(t, session.IsNew)
+		assert.Empty(t, session.ID)
+		assert.NotContains(t, session.Values, "user")
+		assert.Empty(t, client.data)
+		assert.Equal(t, []EventType{SessionCreated, SessionRejected, SessionDeleted}, rec.types())
+
+		// The new session is bound to the request and starts without the
+		// values of the deleted one.
+		res := httptest.NewRecorder()
+		assert.NoError(t, store.Save(req, res, session))
+		session, err = store.New(newRequest("other", "192.0.2.10:1234", res.Result().Cookies()[0]), "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.NotContains(t, session.Values, "user")
+
+		// The original cookie is not accepted anymore.
+		session, err = store.New(newRequest("browser", "192.0.2.10:1234", cookie), "session")
+		assert.NoError(t, err)
+		assert.True(t, session.IsNew)
+		assert.Empty(t, session.Values)
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		store, _, rec, mismatches, cookie := setup(t, BindingFlag)
+
+		session, err := store.New(newRequest("other", "192.0.2.10:1234", cookie), "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.Equal(t, "a", session.Values["user"])
+		assert.Equal(t, []string{"ua"}, *mismatches)
+		assert.Equal(t, []EventType{SessionCreated}, rec.types())
+	})
+
+	t.Run("unbound session", func(t *testing.T) {
+		store, client, _, mismatches, _ := setup(t, BindingReject)
+
+		// Sessions created before the binding was enabled are bound on save.
+		unbound := New(client, [][]byte{[]byte("key")})
+		req := newRequest("browser", "192.0.2.10:1234")
+		res := httptest.NewRecorder()
+		session, _ := unbound.New(req, "session")
+		assert.NoError(t, unbound.Save(req, res, session))
+		assert.NotContains(t, session.Values, bindingValueKey)
+
+		req = newRequest("other", "192.0.2.10:1234", res.Result().Cookies()[0])
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+		assert.Contains(t, session.Values, bindingValueKey)
+		assert.Empty(t, *mismatches)
+	})
+}
@@ -0,0 +1,377 @@
+// Package boltclient provides a file backed redisstore.Client on the embedded
+// key-value store bbolt, for single binary deployments without redis.
+//
+// Every write is committed in its own transaction and synced to disk before
+// it returns, so a crash never leaves a partially written session behind.
+// Expirations are stored next to the values, expired keys are never returned
+// and removed by a background compaction.
+package boltclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/internal/redisvalue"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Client is an implementation of redisstore.Client on top of bbolt.
+type Client struct {
+	db     *bolt.DB
+	bucket []byte
+	now    func() time.Time
+
+	compactInterval time.Duration
+	onError         func(err error)
+	stop            chan struct{}
+	stopOnce        sync.Once
+	done            chan struct{}
+}
+
+var (
+	_ redisstore.Client           = (*Client)(nil)
+	_ redisstore.TTLReader        = (*Client)(nil)
+	_ redisstore.CapabilityProber = (*Client)(nil)
+)
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithBucket sets the name of the bucket that stores the values. By default,
+// the bucket "sessions" is used.
+func WithBucket(name string) Option {
+	return func(c *Client) {
+		c.bucket = []byte(name)
+	}
+}
+
+// WithCompactionInterval sets how often expired keys are deleted. An interval
+// <= 0 disables the compaction. By default, expired keys are deleted every
+// minute.
+func WithCompactionInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.compactInterval = interval
+	}
+}
+
+// WithCompactionErrorHandler sets a function that is called with every error
+// of the compaction.
+func WithCompactionErrorHandler(fn func(err error)) Option {
+	return func(c *Client) {
+		c.onError = fn
+	}
+}
+
+// WithClock sets the function used to read the current time. By default,
+// time.Now is used.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+const (
+	defaultBucket          = "sessions"
+	defaultCompactInterval = time.Minute
+
+	// openTimeout bounds the wait for the file lock held by another process.
+	openTimeout = 5 * time.Second
+
+	// compactBatchSize is the number of keys deleted per transaction, so that
+	// the compaction does not block writes for long.
+	compactBatchSize = 1000
+)
+
+// Open opens or creates the database file at path and starts the compaction.
+// Call Close to stop it and close the file.
+func Open(path string, options ...Option) (*Client, error) {
+	c := &Client{
+		db:              nil,
+		bucket:          []byte(defaultBucket),
+		now:             time.Now,
+		compactInterval: defaultCompactInterval,
+		onError:         func(error) {},
+		stop:            make(chan struct{}),
+		stopOnce:        sync.Once{},
+		done:            make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout}) //nolint: exhaustruct
+	if err != nil {
+		return nil, fmt.Errorf("boltclient: opening database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(c.bucket)
+		return err //nolint: wrapcheck
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("boltclient: creating bucket: %w", err)
+	}
+
+	c.db = db
+
+	if c.compactInterval > 0 {
+		go c.compact()
+	} else {
+		close(c.done)
+	}
+
+	return c, nil
+}
+
+// Get returns the value for a given key or redisstore.ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	var val []byte
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		e, ok := c.lookup(tx, key)
+		if !ok {
+			return redisstore.ErrNotFound
+		}
+
+		// Values are only valid during the transaction.
+		val = append([]byte(nil), e.value...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, wrap("getting value", err)
+	}
+
+	return val, nil
+}
+
+// Set sets the value for a given key. An expiration <= 0 keeps the key
+// forever.
+func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	b, err := redisvalue.Bytes(value)
+	if err != nil {
+		return fmt.Errorf("boltclient: %w", err)
+	}
+
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = c.now().Add(expiration)
+	}
+
+	err = c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).Put([]byte(key), encode(b, expiresAt)) //nolint: wrapcheck
+	})
+	if err != nil {
+		return wrap("setting value", err)
+	}
+
+	return nil
+}
+
+// Del deletes a given key.
+func (c *Client) Del(ctx context.Context, key string) error {
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(c.bucket).Delete([]byte(key)) //nolint: wrapcheck
+	})
+	if err != nil {
+		return wrap("deleting value", err)
+	}
+
+	return nil
+}
+
+// TTL returns the remaining time to live of a key, -1 for keys without
+// expiration and redisstore.ErrNotFound for missing keys.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var ttl time.Duration
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		e, ok := c.lookup(tx, key)
+		if !ok {
+			return redisstore.ErrNotFound
+		}
+
+		ttl = -1
+		if !e.expiresAt.IsZero() {
+			ttl = e.expiresAt.Sub(c.now())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, wrap("getting ttl", err)
+	}
+
+	return ttl, nil
+}
+
+// Probe reports that only expirations are supported beyond GET, SET and DEL,
+// so Admin is not available and flashes are consumed with GET and DEL.
+func (c *Client) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	return redisstore.Capabilities{
+		Server:         "bbolt",
+		Version:        "",
+		Scan:           false,
+		TTL:            true,
+		GetDel:         false,
+		Config:         false,
+		KeyspaceEvents: false,
+		DelEvents:      false,
+	}, nil
+}
+
+// DeleteExpired deletes all expired keys and returns their number. Keys are
+// deleted in batches, each in its own transaction.
+func (c *Client) DeleteExpired() (int, error) {
+	deleted := 0
+
+	// start is the key the next batch continues with, nil for the first key.
+	var start []byte
+
+	for {
+		n := 0
+
+		err := c.db.Update(func(tx *bolt.Tx) error {
+			now := c.now()
+			cur := tx.Bucket(c.bucket).Cursor()
+
+			k, v := cur.First()
+			if start != nil {
+				k, v = cur.Seek(start)
+			}
+
+			for k != nil && n < compactBatchSize {
+				e, err := decode(v)
+				if err == nil && !e.live(now) {
+					// Deleting invalidates the position of the cursor, so seek
+					// to the key after the deleted one.
+					deletedKey := append([]byte(nil), k...)
+					if err := cur.Delete(); err != nil {
+						return err //nolint: wrapcheck
+					}
+					n++
+					k, v = cur.Seek(deletedKey)
+
+					continue
+				}
+
+				k, v = cur.Next()
+			}
+
+			start = append([]byte(nil), k...)
+
+			return nil
+		})
+		if err != nil {
+			return deleted, wrap("deleting expired values", err)
+		}
+
+		deleted += n
+		if n < compactBatchSize || len(start) == 0 {
+			return deleted, nil
+		}
+	}
+}
+
+// Close stops the compaction and closes the database file.
+func (c *Client) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+
+	if err := c.db.Close(); err != nil {
+		return wrap("closing database", err)
+	}
+
+	return nil
+}
+
+// compact deletes expired keys until Close is called.
+func (c *Client) compact() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if _, err := c.DeleteExpired(); err != nil {
+				c.onError(err)
+			}
+		}
+	}
+}
+
+// entry is a decoded value.
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (e entry) live(now time.Time) bool {
+	return e.expiresAt.IsZero() || now.Before(e.expiresAt)
+}
+
+// lookup returns the entry of a key unless it expired.
+func (c *Client) lookup(tx *bolt.Tx, key string) (entry, bool) {
+	v := tx.Bucket(c.bucket).Get([]byte(key))
+	if v == nil {
+		return entry{}, false //nolint: exhaustruct
+	}
+
+	e, err := decode(v)
+	if err != nil || !e.live(c.now()) {
+		return entry{}, false //nolint: exhaustruct
+	}
+
+	return e, true
+}
+
+// headerSize is the size of the expiration stored in front of every value.
+const headerSize = 8
+
+// encode prefixes a value with its expiration in unix nanoseconds, 0 for
+// values without expiration.
+func encode(value []byte, expiresAt time.Time) []byte {
+	b := make([]byte, headerSize+len(value))
+
+	if !expiresAt.IsZero() {
+		binary.BigEndian.PutUint64(b, uint64(expiresAt.UnixNano()))
+	}
+	copy(b[headerSize:], value)
+
+	return b
+}
+
+var errCorrupt = errors.New("boltclient: corrupt value")
+
+func decode(b []byte) (entry, error) {
+	if len(b) < headerSize {
+		return entry{}, errCorrupt //nolint: exhaustruct
+	}
+
+	var expiresAt time.Time
+	if ns := binary.BigEndian.Uint64(b); ns != 0 {
+		expiresAt = time.Unix(0, int64(ns))
+	}
+
+	return entry{value: b[headerSize:], expiresAt: expiresAt}, nil
+}
+
+// wrap adds context to errors other than redisstore.ErrNotFound.
+func wrap(op string, err error) error {
+	if errors.Is(err, redisstore.ErrNotFound) {
+		return err
+	}
+
+	return fmt.Errorf("boltclient: %s: %w", op, err)
+}
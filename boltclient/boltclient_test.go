@@ -0,0 +1,190 @@
+package boltclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func open(t *testing.T, path string, options ...Option) *Client {
+	t.Helper()
+
+	c, err := Open(path, options...)
+	if err != nil {
+		t.Fatal("failed to open client: ", err)
+	}
+
+	return c
+}
+
+func count(t *testing.T, c *Client) int {
+	t.Helper()
+
+	n := 0
+	err := c.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(c.bucket).Stats().KeyN
+		return nil
+	})
+	assert.NoError(t, err)
+
+	return n
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get set del", func(t *testing.T) {
+		c := open(t, filepath.Join(t.TempDir(), "sessions.db"))
+		defer c.Close()
+
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		assert.NoError(t, c.Set(ctx, "int", 42, 0))
+		val, err = c.Get(ctx, "int")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("42"), val)
+
+		assert.Error(t, c.Set(ctx, "struct", struct{}{}, 0))
+
+		assert.NoError(t, c.Del(ctx, "key"))
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+	})
+
+	t.Run("persistence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+
+		c := open(t, path)
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Hour))
+		assert.NoError(t, c.Close())
+
+		c = open(t, path)
+		defer c.Close()
+
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		ttl, err := c.TTL(ctx, "key")
+		assert.NoError(t, err)
+		assert.Greater(t, ttl, 59*time.Minute)
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := open(t, filepath.Join(t.TempDir(), "sessions.db"), WithClock(clock.Now), WithCompactionInterval(0))
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		assert.NoError(t, c.Set(ctx, "forever", "value", 0))
+
+		ttl, err := c.TTL(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl)
+
+		ttl, err = c.TTL(ctx, "forever")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(-1), ttl)
+
+		clock.Advance(time.Minute)
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		_, err = c.TTL(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		assert.Equal(t, 2, count(t, c))
+
+		n, err := c.DeleteExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, 1, count(t, c))
+	})
+
+	t.Run("delete expired in batches", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := open(t, filepath.Join(t.TempDir(), "sessions.db"), WithClock(clock.Now), WithCompactionInterval(0))
+		defer c.Close()
+
+		total := compactBatchSize*2 + 10
+		for i := 0; i < total; i++ {
+			expiration := time.Minute
+			if i%2 == 0 {
+				expiration = time.Hour
+			}
+			assert.NoError(t, c.Set(ctx, fmt.Sprintf("key%05d", i), "value", expiration))
+		}
+
+		clock.Advance(time.Minute)
+		n, err := c.DeleteExpired()
+		assert.NoError(t, err)
+		assert.Equal(t, total/2, n)
+		assert.Equal(t, total/2, count(t, c))
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		c := open(t, filepath.Join(t.TempDir(), "sessions.db"), WithCompactionInterval(10*time.Millisecond))
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Millisecond))
+		assert.Eventually(t, func() bool {
+			return count(t, c) == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestStore(t *testing.T) {
+	c := open(t, filepath.Join(t.TempDir(), "sessions.db"))
+	defer c.Close()
+
+	store := redisstore.New(c, [][]byte{[]byte("secret")}, redisstore.WithKeyPrefix("kiosk_"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "session")
+	assert.NoError(t, err)
+	session.Values["key"] = "value"
+	assert.NoError(t, session.Save(req, res))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+	session, err = store.New(req, "session")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, "value", session.Values["key"])
+}
@@ -0,0 +1,96 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrProbeNotSupported is returned by Store.Probe if the client does not
+// implement CapabilityProber.
+var ErrProbeNotSupported = errors.New("redisstore: client does not support capability probing")
+
+// Capabilities describes which commands beyond GET, SET and DEL the server
+// behind a Client supports. Redis compatible servers like Valkey, KeyDB and
+// Dragonfly implement different subsets of these commands.
+type Capabilities struct {
+	// Server is the name of the server implementation, e.g. "redis",
+	// "valkey", "keydb" or "dragonfly". It is empty if it is unknown.
+	Server string `json:"server"`
+	// Version is the version reported by the server.
+	Version string `json:"version"`
+
+	// Scan reports support for SCAN, used by Admin.
+	Scan bool `json:"scan"`
+	// TTL reports support for PTTL, used by Admin.
+	TTL bool `json:"ttl"`
+	// GetDel reports support for GETDEL, available since redis 6.2.
+	GetDel bool `json:"get_del"`
+	// Config reports support for CONFIG GET and CONFIG SET.
+	Config bool `json:"config"`
+	// KeyspaceEvents reports support for keyevent notifications of expired
+	// keys, probed by subscribing to them and, if CONFIG is supported, by
+	// checking the E and x flags of notify-keyspace-events. Used by the
+	// adapter Watcher.
+	KeyspaceEvents bool `json:"keyspace_events"`
+	// DelEvents reports support for keyevent notifications of deleted keys,
+	// which additionally need the g flag.
+	DelEvents bool `json:"del_events"`
+}
+
+// CapabilityProber is an optional Client capability to detect the features of
+// the server.
+type CapabilityProber interface {
+	// Probe returns the capabilities of the server. Commands that the server
+	// rejects, e.g. because they are unknown or forbidden by an ACL, are
+	// reported as unsupported. Connection errors are returned.
+	Probe(ctx context.Context) (Capabilities, error)
+}
+
+// defaultCapabilities assumes a recent redis server.
+var defaultCapabilities = Capabilities{
+	Server:         "",
+	Version:        "",
+	Scan:           true,
+	TTL:            true,
+	GetDel:         true,
+	Config:         true,
+	KeyspaceEvents: true,
+	DelEvents:      true,
+}
+
+// WithCapabilities sets the server capabilities instead of probing them.
+// By default, the store assumes a recent redis server until Probe is called.
+func WithCapabilities(capabilities Capabilities) Options {
+	return func(s *Store) {
+		s.capabilities = capabilities
+	}
+}
+
+// Probe detects the capabilities of the server and turns off the features
+// that it does not support. It should be called once at startup.
+func (s *Store) Probe(ctx context.Context) (Capabilities, error) {
+	prober, ok := s.client.(CapabilityProber)
+	if !ok {
+		return s.Capabilities(), ErrProbeNotSupported
+	}
+
+	capabilities, err := prober.Probe(ctx)
+	if err != nil {
+		return s.Capabilities(), fmt.Errorf("redisstore(probe): %v", err)
+	}
+
+	s.mu.Lock()
+	s.capabilities = capabilities
+	s.mu.Unlock()
+
+	return capabilities, nil
+}
+
+// Capabilities returns the server capabilities the store currently assumes.
+func (s *Store) Capabilities() Capabilities {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.capabilities
+}
@@ -0,0 +1,68 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/joelrose/redisstore/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type probeClient struct {
+	scanClient
+	caps Capabilities
+	err  error
+}
+
+func (c *probeClient) Probe(context.Context) (Capabilities, error) {
+	return c.caps, c.err
+}
+
+func TestStoreProbe(t *testing.T) {
+	t.Run("defaults to redis", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+
+		store := New(mocks.NewMockRedisClient(mockCtrl), nil)
+
+		caps, err := store.Probe(context.Background())
+		assert.ErrorIs(t, err, ErrProbeNotSupported)
+		assert.Equal(t, defaultCapabilities, caps)
+	})
+
+	t.Run("turns off unsupported features", func(t *testing.T) {
+		client := &probeClient{caps: Capabilities{Server: "dragonfly", TTL: true}}
+		store := New(client, nil)
+
+		caps, err := store.Probe(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, client.caps, caps)
+		assert.Equal(t, client.caps, store.Capabilities())
+
+		_, err = NewAdmin(store)
+		assert.ErrorIs(t, err, ErrScanNotSupported)
+	})
+
+	t.Run("keeps capabilities on error", func(t *testing.T) {
+		client := &probeClient{err: errors.New("connection refused")}
+		store := New(client, nil, WithCapabilities(Capabilities{Scan: true}))
+
+		caps, err := store.Probe(context.Background())
+		assert.Error(t, err)
+		assert.Equal(t, Capabilities{Scan: true}, caps)
+	})
+}
+
+func TestAdminWithoutTTL(t *testing.T) {
+	store, _ := newAdminStore(t)
+	WithCapabilities(Capabilities{Scan: true})(store)
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+
+	info, err := admin.Inspect(context.Background(), "a1")
+	assert.NoError(t, err)
+	assert.Zero(t, info.TTL)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	"github.com/joelrose/redisstore"
+)
+
+type cli struct {
+	admin  *redisstore.Admin
+	out    *output
+	stderr io.Writer
+}
+
+func (c *cli) flags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+
+	return flags
+}
+
+func (c *cli) list(ctx context.Context, args []string) error {
+	flags := c.flags("list")
+	prefix := flags.String("prefix", "", "only list sessions whose ID starts with prefix")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	infos, err := c.admin.List(ctx, *prefix)
+	if err != nil {
+		return err
+	}
+
+	return c.out.sessions(infos)
+}
+
+func (c *cli) show(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: redisstore show <id>")
+		return errUsage
+	}
+
+	info, err := c.admin.Inspect(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return c.out.session(info)
+}
+
+func (c *cli) delete(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprintln(c.stderr, "usage: redisstore delete <id>")
+		return errUsage
+	}
+
+	err := c.admin.Delete(ctx, args[0])
+	if errors.Is(err, redisstore.ErrNotFound) {
+		if err := c.out.deleted(0); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("session %q not found", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.out.deleted(1)
+}
+
+func (c *cli) purge(ctx context.Context, args []string) error {
+	flags := c.flags("purge")
+	prefix := flags.String("prefix", "", "delete sessions whose ID starts with prefix")
+	all := flags.Bool("all", false, "delete all sessions")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	// Require an explicit -all to delete every session of the store.
+	if *prefix == "" && !*all {
+		fmt.Fprintln(c.stderr, "usage: redisstore purge -prefix id | -all")
+		return errUsage
+	}
+
+	deleted, err := c.admin.Purge(ctx, *prefix)
+	if err != nil {
+		return err
+	}
+
+	return c.out.deleted(deleted)
+}
+
+func (c *cli) stats(ctx context.Context, args []string) error {
+	flags := c.flags("stats")
+	prefix := flags.String("prefix", "", "only include sessions whose ID starts with prefix")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	s := newStats()
+	if err := c.admin.Walk(ctx, *prefix, func(info redisstore.SessionInfo) error {
+		s.add(info)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return c.out.stats(s)
+}
+
+func decodeCookie(args []string, out *output, stderr io.Writer) error {
+	flags := flag.NewFlagSet("decode-cookie", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var (
+		keyFile  = flags.String("key-file", "", "JSON key file of a redisstore.KeyRing, instead of -hash-key and -block-key")
+		hashKey  = flags.String("hash-key", "", "hash key, prefix with base64: for base64 encoded keys")
+		blockKey = flags.String("block-key", "", "block key, prefix with base64: for base64 encoded keys")
+		name     = flags.String("name", "session", "cookie name")
+		maxAge   = flags.Int("max-age", 86400*30, "max age of the cookie in seconds, 0 disables the check")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if (*hashKey == "") == (*keyFile == "") || flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: redisstore decode-cookie -hash-key k [-block-key k] | -key-file f [-name n] <cookie>")
+		return errUsage
+	}
+
+	value := strings.TrimSpace(flags.Arg(0))
+
+	if *keyFile != "" {
+		ring, err := redisstore.LoadKeyRingFile(*keyFile)
+		if err != nil {
+			return err
+		}
+
+		ring.SetMaxAge(*maxAge)
+
+		var id string
+		keyID, err := ring.DecodeWithKey(*name, value, &id)
+		if err != nil {
+			return fmt.Errorf("decoding cookie: %v", err)
+		}
+
+		return out.cookie(*name, id, keyID)
+	}
+
+	hash, err := parseKey(*hashKey)
+	if err != nil {
+		return fmt.Errorf("parsing hash key: %v", err)
+	}
+
+	block, err := parseKey(*blockKey)
+	if err != nil {
+		return fmt.Errorf("parsing block key: %v", err)
+	}
+
+	codec := securecookie.New(hash, block)
+	codec.MaxAge(*maxAge)
+
+	var id string
+	if err := codec.Decode(*name, value, &id); err != nil {
+		return fmt.Errorf("decoding cookie: %v", err)
+	}
+
+	return out.cookie(*name, id, "")
+}
+
+// parseKey returns the raw bytes of a key given on the command line.
+func parseKey(key string) ([]byte, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	if encoded := strings.TrimPrefix(key, "base64:"); encoded != key {
+		return base64.StdEncoding.DecodeString(encoded) //nolint: wrapcheck
+	}
+
+	return []byte(key), nil
+}
@@ -0,0 +1,192 @@
+// Command redisstore inspects and manages the sessions of a redisstore.Store.
+//
+// Usage:
+//
+//	redisstore [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	list [-prefix id]               list sessions
+//	show <id>                       show a session including its values
+//	delete <id>                     delete a session, fails if it does not exist
+//	purge -prefix id | -all         delete all sessions matching an ID prefix
+//	stats                           show session count, size and TTL histograms
+//	decode-cookie -hash-key k <c>   decode a cookie value into a session ID
+//
+// Sessions stored with the GobSerializer can only be shown if the types of
+// their values are registered with gob, prefer the JSONSerializer if
+// sessions need to be inspected with this tool.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/adapter"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// errUsage is returned by commands that were called with invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redisstore", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	var (
+		addr       = flags.String("addr", "localhost:6379", "redis address, comma separated cluster node addresses with -cluster")
+		cluster    = flags.Bool("cluster", false, "connect to a redis cluster")
+		hashTags   = flags.Bool("hash-tags", false, "session keys use cluster hash tags")
+		username   = flags.String("username", "", "redis username")
+		password   = flags.String("password", os.Getenv("REDIS_PASSWORD"), "redis password, defaults to $REDIS_PASSWORD")
+		db         = flags.Int("db", 0, "redis database")
+		keyPrefix  = flags.String("key-prefix", "session_", "key prefix of the store")
+		serializer = flags.String("serializer", "gob", "session serializer: gob or json")
+		format     = flags.String("format", "table", "output format: table or json")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	out, err := newOutput(stdout, *format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	cmd, cmdArgs := flags.Arg(0), flags.Args()[1:]
+
+	// decode-cookie works offline and does not need a redis connection.
+	if cmd == "decode-cookie" {
+		return exit(stderr, decodeCookie(cmdArgs, out, stderr))
+	}
+
+	s, err := newSerializer(*serializer)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	var client goredis.UniversalClient
+	if *cluster {
+		client = goredis.NewClusterClient(&goredis.ClusterOptions{ //nolint: exhaustruct
+			Addrs:    strings.Split(*addr, ","),
+			Username: *username,
+			Password: *password,
+		})
+	} else {
+		client = goredis.NewClient(&goredis.Options{ //nolint: exhaustruct
+			Addr:     *addr,
+			Username: *username,
+			Password: *password,
+			DB:       *db,
+		})
+	}
+	defer client.Close()
+
+	options := []redisstore.Options{
+		redisstore.WithKeyPrefix(*keyPrefix),
+		redisstore.WithSerializer(s),
+	}
+	if *hashTags {
+		options = append(options, redisstore.WithClusterHashTags())
+	}
+
+	store := redisstore.New(adapter.UseGoRedis(client), nil, options...)
+
+	// Turn off the features the server does not support instead of relying
+	// on the defaults for a recent redis server.
+	if _, err := store.Probe(ctx); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	admin, err := redisstore.NewAdmin(store)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	c := &cli{admin: admin, out: out, stderr: stderr}
+
+	switch cmd {
+	case "list":
+		err = c.list(ctx, cmdArgs)
+	case "show":
+		err = c.show(ctx, cmdArgs)
+	case "delete":
+		err = c.delete(ctx, cmdArgs)
+	case "purge":
+		err = c.purge(ctx, cmdArgs)
+	case "stats":
+		err = c.stats(ctx, cmdArgs)
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n", cmd)
+		flags.Usage()
+		return 2
+	}
+
+	return exit(stderr, err)
+}
+
+// exit prints err and returns the exit code for it.
+func exit(stderr io.Writer, err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintln(stderr, "error:", err)
+		return 1
+	}
+}
+
+func newSerializer(name string) (redisstore.SessionSerializer, error) {
+	switch name {
+	case "gob":
+		return redisstore.GobSerializer{}, nil
+	case "json":
+		return redisstore.JSONSerializer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown serializer %q", name)
+	}
+}
+
+const usage = `Usage: redisstore [flags] <command> [arguments]
+
+Commands:
+  list [-prefix id]                           list sessions
+  show <id>                                   show a session including its values
+  delete <id>                                 delete a session
+  purge -prefix id | -all                     delete all sessions matching an ID prefix
+  stats                                       show session count, size and TTL histograms
+  decode-cookie -hash-key k [-block-key k] [-name n] <cookie>
+                                              decode a cookie value into a session ID
+
+Flags:
+`
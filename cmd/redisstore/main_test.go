@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/adapter"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func runCLI(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+
+	return stdout.String(), stderr.String(), code
+}
+
+func TestDecodeCookie(t *testing.T) {
+	encoded, err := securecookie.New([]byte("hash"), nil).Encode("session", "abc")
+	assert.NoError(t, err)
+
+	t.Run("table", func(t *testing.T) {
+		stdout, _, code := runCLI(t, "decode-cookie", "-hash-key", "hash", encoded)
+		assert.Equal(t, 0, code)
+		assert.Equal(t, "abc\n", stdout)
+	})
+
+	t.Run("json with base64 key", func(t *testing.T) {
+		stdout, _, code := runCLI(t, "-format", "json", "decode-cookie", "-hash-key", "base64:aGFzaA==", encoded)
+		assert.Equal(t, 0, code)
+		assert.JSONEq(t, `{"name":"session","id":"abc"}`, stdout)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		_, stderr, code := runCLI(t, "decode-cookie", "-hash-key", "other", encoded)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr, "decoding cookie")
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		_, _, code := runCLI(t, "decode-cookie", encoded)
+		assert.Equal(t, 2, code)
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		keys := `{"active": "new", "keys": [{"id": "new", "hash_key": "bmV3"}, {"id": "old", "hash_key": "aGFzaA=="}]}`
+		assert.NoError(t, os.WriteFile(path, []byte(keys), 0o600))
+
+		stdout, _, code := runCLI(t, "-format", "json", "decode-cookie", "-key-file", path, encoded)
+		assert.Equal(t, 0, code)
+		assert.JSONEq(t, `{"name":"session","id":"abc","key_id":"old"}`, stdout)
+
+		_, _, code = runCLI(t, "decode-cookie", "-key-file", path, "-hash-key", "hash", encoded)
+		assert.Equal(t, 2, code)
+	})
+}
+
+func TestUsage(t *testing.T) {
+	_, stderr, code := runCLI(t)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "Usage: redisstore")
+
+	_, _, code = runCLI(t, "-format", "xml", "list")
+	assert.Equal(t, 2, code)
+
+	_, _, code = runCLI(t, "unknown")
+	assert.Equal(t, 2, code)
+
+	_, _, code = runCLI(t, "purge")
+	assert.Equal(t, 2, code)
+}
+
+func TestStats(t *testing.T) {
+	s := newStats()
+	s.add(redisstore.SessionInfo{Size: 100, TTL: -1})
+	s.add(redisstore.SessionInfo{Size: 2000, TTL: 2 * time.Hour})
+	s.add(redisstore.SessionInfo{Size: 1 << 20, TTL: 60 * day})
+
+	assert.Equal(t, 3, s.Count)
+	assert.Equal(t, 100+2000+1<<20, s.TotalSize)
+	assert.Equal(t, []int{1, 0, 1, 0, 0, 1}, counts(s.Size))
+	assert.Equal(t, []int{1, 0, 0, 1, 0, 0, 1}, counts(s.TTL))
+}
+
+func counts(buckets []bucket) []int {
+	c := make([]int, 0, len(buckets))
+	for _, b := range buckets {
+		c = append(c, b.Count)
+	}
+
+	return c
+}
+
+func TestCommands(t *testing.T) {
+	client := goredis.NewClient(&goredis.Options{
+		Addr: "localhost:6379",
+	})
+	store := redisstore.New(
+		adapter.UseGoRedis(client),
+		[][]byte{[]byte("hash")},
+		redisstore.WithKeyPrefix("cli_"),
+		redisstore.WithSerializer(redisstore.JSONSerializer{}),
+	)
+
+	flags := []string{"-key-prefix", "cli_", "-serializer", "json", "-format", "json"}
+	_, _, code := runCLI(t, append(flags, "purge", "-all")...)
+	assert.Equal(t, 0, code)
+
+	ids := make([]string, 0, 2)
+	for _, user := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+
+		session.Values["user"] = user
+		assert.NoError(t, session.Save(req, httptest.NewRecorder()))
+		ids = append(ids, session.ID)
+	}
+
+	stdout, _, code := runCLI(t, append(flags, "list")...)
+	assert.Equal(t, 0, code)
+
+	var list []sessionJSON
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &list))
+	assert.Len(t, list, 2)
+
+	stdout, _, code = runCLI(t, append(flags, "show", ids[1])...)
+	assert.Equal(t, 0, code)
+
+	var show sessionJSON
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &show))
+	assert.Equal(t, map[string]interface{}{"user": "bob"}, show.Values)
+
+	stdout, _, code = runCLI(t, "-key-prefix", "cli_", "-serializer", "json", "show", ids[0])
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "alice")
+
+	stdout, _, code = runCLI(t, append(flags, "stats")...)
+	assert.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(stdout, "{\n  \"count\": 2,"))
+
+	stdout, _, code = runCLI(t, append(flags, "delete", ids[0])...)
+	assert.Equal(t, 0, code)
+	assert.JSONEq(t, `{"deleted":1}`, stdout)
+
+	stdout, stderr, code := runCLI(t, append(flags, "delete", ids[0])...)
+	assert.Equal(t, 1, code)
+	assert.JSONEq(t, `{"deleted":0}`, stdout)
+	assert.Contains(t, stderr, "not found")
+
+	stdout, _, code = runCLI(t, append(flags, "purge", "-all")...)
+	assert.Equal(t, 0, code)
+	assert.JSONEq(t, `{"deleted":1}`, stdout)
+
+	_, stderr, code = runCLI(t, append(flags, "show", ids[1])...)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "key not found")
+}
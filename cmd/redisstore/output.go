@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelrose/redisstore"
+)
+
+// output writes command results as a table or as JSON.
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+func newOutput(w io.Writer, format string) (*output, error) {
+	switch format {
+	case "table":
+		return &output{w: w, json: false}, nil
+	case "json":
+		return &output{w: w, json: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type sessionJSON struct {
+	ID     string                 `json:"id"`
+	Key    string                 `json:"key"`
+	TTL    string                 `json:"ttl"`
+	Size   int                    `json:"size"`
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+func toJSON(info redisstore.SessionInfo) sessionJSON {
+	var values map[string]interface{}
+	if info.Values != nil {
+		values = make(map[string]interface{}, len(info.Values))
+		for k, v := range info.Values {
+			values[fmt.Sprint(k)] = v
+		}
+	}
+
+	return sessionJSON{
+		ID:     info.ID,
+		Key:    info.Key,
+		TTL:    formatTTL(info.TTL),
+		Size:   info.Size,
+		Values: values,
+	}
+}
+
+func (o *output) sessions(infos []redisstore.SessionInfo) error {
+	if o.json {
+		sessions := make([]sessionJSON, 0, len(infos))
+		for _, info := range infos {
+			sessions = append(sessions, toJSON(info))
+		}
+
+		return o.encode(sessions)
+	}
+
+	tw := o.table()
+	fmt.Fprintln(tw, "ID\tTTL\tSIZE")
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", info.ID, formatTTL(info.TTL), info.Size)
+	}
+
+	return tw.Flush()
+}
+
+func (o *output) session(info *redisstore.SessionInfo) error {
+	if o.json {
+		return o.encode(toJSON(*info))
+	}
+
+	tw := o.table()
+	fmt.Fprintf(tw, "ID\t%s\n", info.ID)
+	fmt.Fprintf(tw, "KEY\t%s\n", info.Key)
+	fmt.Fprintf(tw, "TTL\t%s\n", formatTTL(info.TTL))
+	fmt.Fprintf(tw, "SIZE\t%d\n", info.Size)
+
+	keys := make([]string, 0, len(info.Values))
+	values := make(map[string]interface{}, len(info.Values))
+	for k, v := range info.Values {
+		key := fmt.Sprint(k)
+		keys = append(keys, key)
+		values[key] = v
+	}
+	sort.Strings(keys)
+
+	if len(keys) > 0 {
+		fmt.Fprintln(tw, "\nKEY\tVALUE")
+	}
+	for _, key := range keys {
+		fmt.Fprintf(tw, "%s\t%v\n", key, values[key])
+	}
+
+	return tw.Flush()
+}
+
+func (o *output) deleted(count int) error {
+	if o.json {
+		return o.encode(struct {
+			Deleted int `json:"deleted"`
+		}{count})
+	}
+
+	_, err := fmt.Fprintf(o.w, "deleted %d session(s)\n", count)
+
+	return err //nolint: wrapcheck
+}
+
+func (o *output) stats(s *stats) error {
+	if o.json {
+		return o.encode(s)
+	}
+
+	tw := o.table()
+	fmt.Fprintf(tw, "SESSIONS\t%d\n", s.Count)
+	fmt.Fprintf(tw, "TOTAL SIZE\t%d\n", s.TotalSize)
+
+	fmt.Fprintln(tw, "\nSIZE\tCOUNT")
+	for _, b := range s.Size {
+		fmt.Fprintf(tw, "%s\t%d\n", b.Label, b.Count)
+	}
+
+	fmt.Fprintln(tw, "\nTTL\tCOUNT")
+	for _, b := range s.TTL {
+		fmt.Fprintf(tw, "%s\t%d\n", b.Label, b.Count)
+	}
+
+	return tw.Flush()
+}
+
+func (o *output) cookie(name, id, keyID string) error {
+	if o.json {
+		return o.encode(struct {
+			Name  string `json:"name"`
+			ID    string `json:"id"`
+			KeyID string `json:"key_id,omitempty"`
+		}{name, id, keyID})
+	}
+
+	if keyID != "" {
+		_, err := fmt.Fprintf(o.w, "%s\t(key %s)\n", id, keyID)
+
+		return err //nolint: wrapcheck
+	}
+
+	_, err := fmt.Fprintln(o.w, id)
+
+	return err //nolint: wrapcheck
+}
+
+func (o *output) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+}
+
+func (o *output) encode(v interface{}) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	return enc.Encode(v) //nolint: wrapcheck
+}
+
+func formatTTL(ttl time.Duration) string {
+	switch {
+	case ttl < 0:
+		return "none"
+	case ttl == 0:
+		return "unknown"
+	default:
+		return ttl.Round(time.Second).String()
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/joelrose/redisstore"
+)
+
+// bucket is a single histogram bucket counting values up to Max.
+type bucket struct {
+	Label string `json:"label"`
+	Max   int64  `json:"-"`
+	Count int    `json:"count"`
+}
+
+type stats struct {
+	Count     int      `json:"count"`
+	TotalSize int      `json:"total_size"`
+	Size      []bucket `json:"size"`
+	TTL       []bucket `json:"ttl"`
+}
+
+const day = 24 * time.Hour
+
+func newStats() *stats {
+	return &stats{
+		Count:     0,
+		TotalSize: 0,
+		Size: []bucket{
+			{Label: "<= 256B", Max: 256, Count: 0},
+			{Label: "<= 1KiB", Max: 1 << 10, Count: 0},
+			{Label: "<= 4KiB", Max: 4 << 10, Count: 0},
+			{Label: "<= 16KiB", Max: 16 << 10, Count: 0},
+			{Label: "<= 64KiB", Max: 64 << 10, Count: 0},
+			{Label: "> 64KiB", Max: math.MaxInt64, Count: 0},
+		},
+		TTL: []bucket{
+			{Label: "none", Max: -1, Count: 0},
+			{Label: "unknown", Max: 0, Count: 0},
+			{Label: "<= 1h", Max: int64(time.Hour), Count: 0},
+			{Label: "<= 1d", Max: int64(day), Count: 0},
+			{Label: "<= 7d", Max: int64(7 * day), Count: 0},
+			{Label: "<= 30d", Max: int64(30 * day), Count: 0},
+			{Label: "> 30d", Max: math.MaxInt64, Count: 0},
+		},
+	}
+}
+
+func (s *stats) add(info redisstore.SessionInfo) {
+	s.Count++
+	s.TotalSize += info.Size
+
+	observe(s.Size, int64(info.Size))
+
+	ttl := int64(info.TTL)
+	if ttl < 0 {
+		ttl = -1
+	}
+	observe(s.TTL, ttl)
+}
+
+// observe increments the first bucket that v fits into.
+func observe(buckets []bucket, v int64) {
+	for i := range buckets {
+		if v <= buckets[i].Max {
+			buckets[i].Count++
+			return
+		}
+	}
+}
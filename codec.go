@@ -0,0 +1,193 @@
+package redisstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// CookieCodec encodes session IDs into cookie values and verifies them.
+//
+// Codecs that implement SetMaxAge(age int) are updated by Store.SetMaxAge.
+type CookieCodec interface {
+	// Encode returns the cookie value of a session ID.
+	Encode(name, id string) (string, error)
+	// Decode returns the session ID of a cookie value or an error if the value
+	// is invalid or expired.
+	Decode(name, value string) (string, error)
+}
+
+// WithCookieCodec sets the codec of session cookies. By default, cookies are
+// encoded with securecookie and the Codecs of the store.
+func WithCookieCodec(codec CookieCodec) Options {
+	return func(s *Store) {
+		s.cookieCodec = codec
+	}
+}
+
+// SecureCookieCodec encodes cookies with the first codec and decodes them with
+// any of the codecs, like securecookie.EncodeMulti and DecodeMulti.
+type SecureCookieCodec struct {
+	Codecs []securecookie.Codec
+}
+
+var _ CookieCodec = SecureCookieCodec{}
+
+// Encode implements CookieCodec.
+func (c SecureCookieCodec) Encode(name, id string) (string, error) {
+	return securecookie.EncodeMulti(name, id, c.Codecs...) //nolint: wrapcheck
+}
+
+// Decode implements CookieCodec.
+func (c SecureCookieCodec) Decode(name, value string) (string, error) {
+	var id string
+	if err := securecookie.DecodeMulti(name, value, &id, c.Codecs...); err != nil {
+		return "", err //nolint: wrapcheck
+	}
+
+	return id, nil
+}
+
+// SetMaxAge sets the max age of all codecs that are *securecookie.SecureCookie.
+func (c SecureCookieCodec) SetMaxAge(age int) {
+	for _, codec := range c.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+var (
+	errTokenMalformed = errors.New("redisstore(token): malformed token")
+	errTokenKey       = errors.New("redisstore(token): unknown key")
+	errTokenSignature = errors.New("redisstore(token): invalid signature")
+	errTokenExpired   = errors.New("redisstore(token): expired token")
+)
+
+// TokenCodec encodes session IDs as compact HMAC signed tokens that can be
+// verified without securecookie, e.g. by edge proxies:
+//
+//	<key id>.<session id>.<issued at>.<signature>
+//
+// The issued at time is in unix seconds. The signature is the unpadded base64
+// URL encoding of the HMAC-SHA256 of "<cookie name>|<key id>.<session id>.<issued at>"
+// with the hash key of the key. Tokens are signed, not encrypted, so the
+// session ID is readable. Key IDs must not contain '.'.
+type TokenCodec struct {
+	keys   []SigningKey // the active key comes first
+	maxAge int64        // accessed atomically
+	now    func() time.Time
+}
+
+var _ CookieCodec = (*TokenCodec)(nil)
+
+// NewTokenCodec returns a TokenCodec that signs tokens with the hash key of
+// active and accepts tokens signed with any of the verify keys. Block keys are
+// not supported.
+func NewTokenCodec(active SigningKey, verify ...SigningKey) (*TokenCodec, error) {
+	keys := append([]SigningKey{active}, verify...)
+
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || len(key.HashKey) == 0 {
+			return nil, errors.New("redisstore(token): key id and hash key are required")
+		}
+		if strings.Contains(key.ID, ".") {
+			return nil, fmt.Errorf("redisstore(token): key id %q contains '.'", key.ID)
+		}
+		if len(key.BlockKey) > 0 {
+			return nil, fmt.Errorf("redisstore(token): key %q has a block key", key.ID)
+		}
+		if _, ok := seen[key.ID]; ok {
+			return nil, fmt.Errorf("redisstore(token): duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = struct{}{}
+	}
+
+	return &TokenCodec{
+		keys:   keys,
+		maxAge: defaultMaxAge,
+		now:    time.Now,
+	}, nil
+}
+
+// SetMaxAge sets the max age in seconds of accepted tokens. An age <= 0
+// disables the check.
+func (c *TokenCodec) SetMaxAge(age int) {
+	atomic.StoreInt64(&c.maxAge, int64(age))
+}
+
+// Encode implements CookieCodec.
+func (c *TokenCodec) Encode(name, id string) (string, error) {
+	if id == "" {
+		return "", errTokenMalformed
+	}
+
+	key := c.keys[0]
+	payload := key.ID + "." + id + "." + strconv.FormatInt(c.now().Unix(), 10)
+
+	return payload + "." + sign(key.HashKey, name, payload), nil
+}
+
+// Decode implements CookieCodec.
+func (c *TokenCodec) Decode(name, value string) (string, error) {
+	keyID, rest, ok := strings.Cut(value, ".")
+	if !ok {
+		return "", errTokenMalformed
+	}
+
+	// The session ID may contain '.', so the last two fields are cut first.
+	sigAt := strings.LastIndexByte(rest, '.')
+	if sigAt < 0 {
+		return "", errTokenMalformed
+	}
+	issuedAt := strings.LastIndexByte(rest[:sigAt], '.')
+	if issuedAt <= 0 {
+		return "", errTokenMalformed
+	}
+
+	id, signature := rest[:issuedAt], rest[sigAt+1:]
+
+	var hashKey []byte
+	for _, key := range c.keys {
+		if key.ID == keyID {
+			hashKey = key.HashKey
+			break
+		}
+	}
+	if hashKey == nil {
+		return "", errTokenKey
+	}
+
+	payload := value[:len(keyID)+1+sigAt]
+	if !hmac.Equal([]byte(signature), []byte(sign(hashKey, name, payload))) {
+		return "", errTokenSignature
+	}
+
+	issued, err := strconv.ParseInt(rest[issuedAt+1:sigAt], 10, 64)
+	if err != nil {
+		return "", errTokenMalformed
+	}
+
+	if maxAge := atomic.LoadInt64(&c.maxAge); maxAge > 0 && c.now().Unix()-issued > maxAge {
+		return "", errTokenExpired
+	}
+
+	return id, nil
+}
+
+// sign returns the signature of a token payload.
+func sign(hashKey []byte, name, payload string) string {
+	mac := hmac.New(sha256.New, hashKey)
+	mac.Write([]byte(name + "|" + payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,161 @@
+package redisstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureCookieCodec(t *testing.T) {
+	old := securecookie.New([]byte("old"), nil)
+	codec := SecureCookieCodec{Codecs: []securecookie.Codec{securecookie.New([]byte("new"), nil), old}}
+
+	encoded, err := codec.Encode("session", "id")
+	assert.NoError(t, err)
+
+	id, err := codec.Decode("session", encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", id)
+
+	encoded, err = old.Encode("session", "old-id")
+	assert.NoError(t, err)
+
+	id, err = codec.Decode("session", encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "old-id", id)
+
+	_, err = codec.Decode("other", encoded)
+	assert.Error(t, err)
+}
+
+func TestNewTokenCodec(t *testing.T) {
+	_, err := NewTokenCodec(SigningKey{ID: "", HashKey: []byte("hash")})
+	assert.Error(t, err)
+
+	_, err = NewTokenCodec(SigningKey{ID: "a.b", HashKey: []byte("hash")})
+	assert.Error(t, err)
+
+	_, err = NewTokenCodec(SigningKey{ID: "a", HashKey: []byte("hash"), BlockKey: []byte("0123456789abcdef")})
+	assert.Error(t, err)
+
+	_, err = NewTokenCodec(SigningKey{ID: "a", HashKey: []byte("hash")}, SigningKey{ID: "a", HashKey: []byte("other")})
+	assert.ErrorContains(t, err, "duplicate key id")
+}
+
+func TestTokenCodec(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	oldKey := SigningKey{ID: "k1", HashKey: []byte("old-hash")}
+	oldCodec, err := NewTokenCodec(oldKey)
+	assert.NoError(t, err)
+	oldCodec.now = clock
+
+	codec, err := NewTokenCodec(SigningKey{ID: "k2", HashKey: []byte("new-hash")}, oldKey)
+	assert.NoError(t, err)
+	codec.now = clock
+
+	token, err := codec.Encode("session", "abc")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "k2.abc.1700000000."))
+
+	// The signature can be verified without this package.
+	mac := hmac.New(sha256.New, []byte("new-hash"))
+	mac.Write([]byte("session|k2.abc.1700000000"))
+	assert.Equal(t, "k2.abc.1700000000."+base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), token)
+
+	id, err := codec.Decode("session", token)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", id)
+
+	t.Run("verify key", func(t *testing.T) {
+		token, err := oldCodec.Encode("session", "a.b")
+		assert.NoError(t, err)
+
+		id, err := codec.Decode("session", token)
+		assert.NoError(t, err)
+		assert.Equal(t, "a.b", id)
+
+		_, err = oldCodec.Decode("session", mustEncode(t, codec, "session", "abc"))
+		assert.ErrorIs(t, err, errTokenKey)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := codec.Decode("other", token)
+		assert.ErrorIs(t, err, errTokenSignature)
+
+		_, err = codec.Decode("session", strings.Replace(token, "abc", "abd", 1))
+		assert.ErrorIs(t, err, errTokenSignature)
+
+		for _, value := range []string{"", "k2", "k2.abc", "k2.abc.1700000000", "k2..sig"} {
+			_, err = codec.Decode("session", value)
+			assert.Error(t, err, value)
+		}
+
+		_, err = codec.Encode("session", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		codec.SetMaxAge(60)
+		defer codec.SetMaxAge(defaultMaxAge)
+
+		now = now.Add(time.Minute)
+		_, err := codec.Decode("session", token)
+		assert.NoError(t, err)
+
+		now = now.Add(time.Second)
+		_, err = codec.Decode("session", token)
+		assert.ErrorIs(t, err, errTokenExpired)
+
+		codec.SetMaxAge(0)
+		_, err = codec.Decode("session", token)
+		assert.NoError(t, err)
+	})
+}
+
+func mustEncode(t *testing.T, codec CookieCodec, name, id string) string {
+	t.Helper()
+
+	encoded, err := codec.Encode(name, id)
+	assert.NoError(t, err)
+
+	return encoded
+}
+
+func TestStoreWithCookieCodec(t *testing.T) {
+	codec, err := NewTokenCodec(SigningKey{ID: "k1", HashKey: []byte("hash")})
+	assert.NoError(t, err)
+
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, nil, WithCookieCodec(codec))
+	assert.Equal(t, int64(defaultMaxAge), codec.maxAge)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	session, _ := store.New(req, "session")
+	session.Values["user"] = "a"
+	assert.NoError(t, store.Save(req, res, session))
+
+	cookie := res.Result().Cookies()[0]
+	assert.True(t, strings.HasPrefix(cookie.Value, "k1."+session.ID+"."))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	loaded, err := store.New(req, "session")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew)
+	assert.Equal(t, session.ID, loaded.ID)
+	assert.Equal(t, "a", loaded.Values["user"])
+
+	store.SetMaxAge(10)
+	assert.Equal(t, int64(10), codec.maxAge)
+}
@@ -0,0 +1,304 @@
+// Package csrf protects against cross-site request forgery with synchronizer
+// tokens stored in a redisstore session, so no separate CSRF cookie is needed.
+//
+// Every session gets a random token that is stored in its values. The token is
+// bound to the session ID and replaced once the ID changes, e.g. after
+// Store.Regenerate. Per-form tokens are derived from the session token, valid
+// for a single form action and expire on their own.
+package csrf
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/joelrose/redisstore"
+)
+
+var (
+	// ErrNoSession is returned if the request was not handled by Protect.
+	ErrNoSession = errors.New("csrf: no session in request")
+	// ErrTokenMissing is the failure reason of requests without a token.
+	ErrTokenMissing = errors.New("csrf: token missing")
+	// ErrTokenInvalid is the failure reason of requests with a wrong token.
+	ErrTokenInvalid = errors.New("csrf: token invalid")
+	// ErrTokenExpired is the failure reason of requests with an expired form
+	// token.
+	ErrTokenExpired = errors.New("csrf: token expired")
+)
+
+const (
+	// tokenKey is the session value that stores the session token.
+	tokenKey = "_csrf_token"
+	// tokenSessionKey is the session value that stores the hash of the session
+	// ID the token was issued for.
+	tokenSessionKey = "_csrf_session"
+
+	// formTokenPrefix marks per-form tokens.
+	formTokenPrefix = "f."
+
+	tokenSize = 32
+
+	defaultHeader = "X-CSRF-Token"
+	defaultField  = "csrf_token"
+)
+
+// Option configures Protect.
+type Option func(p *protector)
+
+// WithHeader sets the request header that carries the token. By default, the
+// header X-CSRF-Token is used.
+func WithHeader(header string) Option {
+	return func(p *protector) {
+		p.header = header
+	}
+}
+
+// WithField sets the form field that carries the token. By default, the field
+// csrf_token is used.
+func WithField(field string) Option {
+	return func(p *protector) {
+		p.field = field
+	}
+}
+
+// WithErrorHandler sets the handler of rejected requests. FailureReason
+// returns why a request was rejected. By default, the request is answered with
+// 403 Forbidden.
+func WithErrorHandler(handler http.Handler) Option {
+	return func(p *protector) {
+		p.errorHandler = handler
+	}
+}
+
+type protector struct {
+	store        *redisstore.Store
+	name         string
+	header       string
+	field        string
+	errorHandler http.Handler
+}
+
+type contextKey int
+
+const (
+	stateKey contextKey = iota
+	reasonKey
+)
+
+// state is the session of a protected request.
+type state struct {
+	store   *redisstore.Store
+	session *sessions.Session
+}
+
+// Protect returns a middleware that validates the token of requests with
+// unsafe methods against the named session of store. Requests with the
+// methods GET, HEAD, OPTIONS and TRACE are not checked.
+func Protect(store *redisstore.Store, name string, options ...Option) func(http.Handler) http.Handler {
+	p := &protector{
+		store:        store,
+		name:         name,
+		header:       defaultHeader,
+		field:        defaultField,
+		errorHandler: http.HandlerFunc(forbidden),
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The session is shared with handlers through the registry of the
+			// request. Invalid cookies yield a new session.
+			session, _ := p.store.Get(r, p.name)
+
+			r = r.WithContext(context.WithValue(r.Context(), stateKey, &state{store: p.store, session: session}))
+
+			if safeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := p.verify(r, session); err != nil {
+				r = r.WithContext(context.WithValue(r.Context(), reasonKey, err))
+				p.errorHandler.ServeHTTP(w, r)
+
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FailureReason returns why Protect rejected a request.
+func FailureReason(r *http.Request) error {
+	err, _ := r.Context().Value(reasonKey).(error)
+	return err
+}
+
+// Token returns the session token of a request handled by Protect. A new
+// token is stored in the session and saved right away.
+func Token(w http.ResponseWriter, r *http.Request) (string, error) {
+	st, ok := r.Context().Value(stateKey).(*state)
+	if !ok {
+		return "", ErrNoSession
+	}
+
+	if token, ok := sessionToken(st.session); ok {
+		return token, nil
+	}
+
+	// The token is bound to the session ID, which is assigned on the first
+	// save of a new session.
+	if st.session.ID == "" {
+		if err := st.store.Save(r, w, st.session); err != nil {
+			return "", fmt.Errorf("csrf: saving session: %v", err)
+		}
+	}
+
+	token, err := issue(st.session)
+	if err != nil {
+		return "", err
+	}
+
+	if err := st.store.Save(r, w, st.session); err != nil {
+		return "", fmt.Errorf("csrf: saving session: %v", err)
+	}
+
+	return token, nil
+}
+
+// FormToken returns a token that is only valid for requests to the path
+// action within ttl.
+func FormToken(w http.ResponseWriter, r *http.Request, action string, ttl time.Duration) (string, error) {
+	token, err := Token(w, r)
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	return formTokenPrefix + expires + "." + signForm(token, action, expires), nil
+}
+
+// Rotate replaces the session token, e.g. after a privilege change without a
+// new session ID. The session must be saved afterwards.
+func Rotate(session *sessions.Session) error {
+	_, err := issue(session)
+	return err
+}
+
+// verify checks the token sent with a request.
+func (p *protector) verify(r *http.Request, session *sessions.Session) error {
+	sent := r.Header.Get(p.header)
+	if sent == "" {
+		sent = r.PostFormValue(p.field)
+	}
+	if sent == "" {
+		return ErrTokenMissing
+	}
+
+	token, ok := sessionToken(session)
+	if !ok {
+		return ErrTokenInvalid
+	}
+
+	if !strings.HasPrefix(sent, formTokenPrefix) {
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			return ErrTokenInvalid
+		}
+
+		return nil
+	}
+
+	expires, signature, ok := strings.Cut(strings.TrimPrefix(sent, formTokenPrefix), ".")
+	if !ok {
+		return ErrTokenInvalid
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(signForm(token, r.URL.Path, expires))) {
+		return ErrTokenInvalid
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrTokenInvalid
+	}
+	if time.Now().Unix() > unix {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// sessionToken returns the token of a session unless it was issued for
+// another session ID.
+func sessionToken(session *sessions.Session) (string, bool) {
+	token, ok := session.Values[tokenKey].(string)
+	if !ok || token == "" {
+		return "", false
+	}
+
+	issuedFor, _ := session.Values[tokenSessionKey].(string)
+	if session.ID == "" || issuedFor != hashID(session.ID) {
+		return "", false
+	}
+
+	return token, true
+}
+
+// issue stores a new token in the session.
+func issue(session *sessions.Session) (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("csrf: generating token: %v", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	session.Values[tokenKey] = token
+	session.Values[tokenSessionKey] = hashID(session.ID)
+
+	return token, nil
+}
+
+// signForm returns the signature of a form token.
+func signForm(token, action, expires string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(action + "|" + expires))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashID returns the hex encoded SHA-256 of a session ID, so that the ID is
+// not duplicated in the session values.
+func hashID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
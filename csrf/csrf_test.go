@@ -0,0 +1,163 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/memstore"
+	"github.com/stretchr/testify/assert"
+)
+
+type app struct {
+	store   *redisstore.Store
+	handler http.Handler
+	cookies []*http.Cookie
+	reason  error
+}
+
+func newApp(t *testing.T) *app {
+	t.Helper()
+
+	a := &app{store: redisstore.New(memstore.New(), [][]byte{[]byte("key")})}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token, err := Token(w, r)
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte(token))
+	})
+	mux.HandleFunc("/form-token", func(w http.ResponseWriter, r *http.Request) {
+		token, err := FormToken(w, r, "/submit", time.Minute)
+		assert.NoError(t, err)
+		_, _ = w.Write([]byte(token))
+	})
+	mux.HandleFunc("/regenerate", func(w http.ResponseWriter, r *http.Request) {
+		session, err := a.store.Get(r, "session")
+		assert.NoError(t, err)
+		assert.NoError(t, a.store.Regenerate(r, w, session))
+	})
+	mux.HandleFunc("/submit", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {})
+
+	a.handler = Protect(a.store, "session", WithErrorHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.reason = FailureReason(r)
+		w.WriteHeader(http.StatusForbidden)
+	})))(mux)
+
+	return a
+}
+
+func (a *app) do(req *http.Request) *httptest.ResponseRecorder {
+	for _, c := range a.cookies {
+		req.AddCookie(c)
+	}
+
+	a.reason = nil
+	res := httptest.NewRecorder()
+	a.handler.ServeHTTP(res, req)
+
+	if cookies := res.Result().Cookies(); len(cookies) > 0 {
+		a.cookies = cookies[len(cookies)-1:]
+	}
+
+	return res
+}
+
+func (a *app) get(path string) string {
+	return a.do(httptest.NewRequest(http.MethodGet, path, nil)).Body.String()
+}
+
+func (a *app) postHeader(path, token string) int {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("X-CSRF-Token", token)
+
+	return a.do(req).Code
+}
+
+func (a *app) postForm(path, token string) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(url.Values{"csrf_token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return a.do(req).Code
+}
+
+func TestProtect(t *testing.T) {
+	a := newApp(t)
+
+	assert.Equal(t, http.StatusForbidden, a.postHeader("/submit", ""))
+	assert.True(t, errors.Is(a.reason, ErrTokenMissing))
+
+	token := a.get("/token")
+	assert.NotEmpty(t, token)
+	assert.Equal(t, token, a.get("/token"))
+
+	assert.Equal(t, http.StatusOK, a.postHeader("/submit", token))
+	assert.Equal(t, http.StatusOK, a.postForm("/submit", token))
+
+	assert.Equal(t, http.StatusForbidden, a.postHeader("/submit", token+"x"))
+	assert.True(t, errors.Is(a.reason, ErrTokenInvalid))
+
+	// Safe methods are not checked.
+	assert.Equal(t, http.StatusOK, a.do(httptest.NewRequest(http.MethodGet, "/submit", nil)).Code)
+}
+
+func TestProtectWithoutSession(t *testing.T) {
+	a := newApp(t)
+
+	assert.Equal(t, http.StatusForbidden, a.postHeader("/submit", "token"))
+	assert.True(t, errors.Is(a.reason, ErrTokenInvalid))
+}
+
+func TestTokenRotatesOnRegenerate(t *testing.T) {
+	a := newApp(t)
+
+	token := a.get("/token")
+	assert.Equal(t, http.StatusOK, a.postHeader("/regenerate", token))
+	assert.Equal(t, http.StatusForbidden, a.postHeader("/submit", token))
+
+	rotated := a.get("/token")
+	assert.NotEqual(t, token, rotated)
+	assert.Equal(t, http.StatusOK, a.postHeader("/submit", rotated))
+}
+
+func TestFormToken(t *testing.T) {
+	a := newApp(t)
+
+	token := a.get("/form-token")
+	assert.True(t, strings.HasPrefix(token, formTokenPrefix))
+
+	assert.Equal(t, http.StatusOK, a.postForm("/submit", token))
+
+	// Form tokens are only valid for their action.
+	assert.Equal(t, http.StatusForbidden, a.postForm("/other", token))
+	assert.True(t, errors.Is(a.reason, ErrTokenInvalid))
+
+	session, err := a.store.New(requestWithCookies(a.cookies), "session")
+	assert.NoError(t, err)
+	sessionToken := session.Values[tokenKey].(string)
+
+	expires := "1"
+	expired := formTokenPrefix + expires + "." + signForm(sessionToken, "/submit", expires)
+	assert.Equal(t, http.StatusForbidden, a.postForm("/submit", expired))
+	assert.True(t, errors.Is(a.reason, ErrTokenExpired))
+}
+
+func TestTokenWithoutProtect(t *testing.T) {
+	_, err := Token(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, err, ErrNoSession)
+}
+
+func requestWithCookies(cookies []*http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	return req
+}
@@ -0,0 +1,275 @@
+package redisstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// EventType describes what happened to a session.
+type EventType int
+
+const (
+	// SessionCreated is emitted when a session is saved to redis for the first time.
+	SessionCreated EventType = iota + 1
+	// SessionSaved is emitted when an existing session is saved again.
+	SessionSaved
+	// SessionDeleted is emitted when a session is removed from redis.
+	SessionDeleted
+	// SessionRejected is emitted when a request carries a session cookie that
+	// cannot be decoded or does not reference a stored session.
+	SessionRejected
+	// ImpersonationStarted is emitted when a session starts to impersonate
+	// another user.
+	ImpersonationStarted
+	// ImpersonationStopped is emitted when the original session of an
+	// impersonation is restored.
+	ImpersonationStopped
+	// ImpersonationExpired is emitted when a request carries an impersonated
+	// session whose impersonation expired.
+	ImpersonationExpired
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case SessionCreated:
+		return "created"
+	case SessionSaved:
+		return "saved"
+	case SessionDeleted:
+		return "deleted"
+	case SessionRejected:
+		return "rejected"
+	case ImpersonationStarted:
+		return "impersonation_started"
+	case ImpersonationStopped:
+		return "impersonation_stopped"
+	case ImpersonationExpired:
+		return "impersonation_expired"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a single session lifecycle transition.
+type Event struct {
+	Type EventType
+	// Name is the session (cookie) name.
+	Name string
+	// HashedID is the hex encoded SHA-256 of the session ID, so the bearer
+	// token itself never ends up in audit logs. It is empty if the ID is unknown.
+	HashedID string
+	// Request is the request during which the event happened.
+	Request *http.Request
+	// Keys lists the session value keys that changed. Only set for
+	// SessionCreated and SessionSaved events.
+	Keys []string
+	// Err is the reason a session was rejected.
+	Err error
+	// Impersonation describes the impersonation of Impersonation* events.
+	Impersonation *Impersonation
+	Time          time.Time
+}
+
+// EventHandler receives session lifecycle events.
+type EventHandler interface {
+	HandleEvent(event Event)
+}
+
+// EventHandlerFunc is an adapter to allow the use of ordinary functions as
+// event handlers.
+type EventHandlerFunc func(event Event)
+
+// HandleEvent calls f(event).
+func (f EventHandlerFunc) HandleEvent(event Event) {
+	f(event)
+}
+
+// WithEventHandler registers a handler that is called synchronously for every
+// session lifecycle event. It can be used multiple times to register several
+// handlers. Wrap the handler with NewAsyncEventHandler to move it off the
+// request path.
+//
+// Computing the changed keys requires reading the previous session state, so
+// every Save issues an additional GET once a handler is registered.
+func WithEventHandler(handler EventHandler) Options {
+	return func(s *Store) {
+		s.eventHandlers = append(s.eventHandlers, handler)
+	}
+}
+
+// AsyncEventHandler delivers events to the wrapped handler from a background
+// goroutine using a bounded queue. Events are dropped when the queue is full.
+type AsyncEventHandler struct {
+	handler EventHandler
+	queue   chan Event
+	done    chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	dropped uint64 // accessed atomically
+}
+
+var _ EventHandler = (*AsyncEventHandler)(nil)
+
+// NewAsyncEventHandler starts a goroutine that delivers events to handler.
+// queueSize bounds the number of pending events. Call Close to stop it.
+func NewAsyncEventHandler(handler EventHandler, queueSize int) *AsyncEventHandler {
+	h := &AsyncEventHandler{
+		handler: handler,
+		queue:   make(chan Event, queueSize),
+		done:    make(chan struct{}),
+		mu:      sync.RWMutex{},
+		closed:  false,
+		dropped: 0,
+	}
+
+	go h.run()
+
+	return h
+}
+
+func (h *AsyncEventHandler) run() {
+	defer close(h.done)
+
+	for event := range h.queue {
+		h.handler.HandleEvent(event)
+	}
+}
+
+// HandleEvent enqueues the event without blocking.
+func (h *AsyncEventHandler) HandleEvent(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		atomic.AddUint64(&h.dropped, 1)
+		return
+	}
+
+	select {
+	case h.queue <- event:
+	default:
+		atomic.AddUint64(&h.dropped, 1)
+	}
+}
+
+// Dropped returns the number of events that were discarded because the queue
+// was full or the handler was closed.
+func (h *AsyncEventHandler) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Close stops accepting events and waits until all queued events have been
+// delivered.
+func (h *AsyncEventHandler) Close() {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+
+	<-h.done
+}
+
+// emit delivers an event to all registered handlers.
+func (s *Store) emit(typ EventType, r *http.Request, session *sessions.Session, keys []string, err error) {
+	s.emitImpersonation(typ, r, session, keys, err, nil)
+}
+
+// emitImpersonation delivers an event with an impersonation to all registered
+// handlers.
+func (s *Store) emitImpersonation(
+	typ EventType,
+	r *http.Request,
+	session *sessions.Session,
+	keys []string,
+	err error,
+	impersonation *Impersonation,
+) {
+	if len(s.eventHandlers) == 0 {
+		return
+	}
+
+	event := Event{
+		Type:          typ,
+		Name:          session.Name(),
+		HashedID:      hashID(session.ID),
+		Request:       r,
+		Keys:          keys,
+		Err:           err,
+		Impersonation: impersonation,
+		Time:          time.Now(),
+	}
+
+	for _, handler := range s.eventHandlers {
+		handler.HandleEvent(event)
+	}
+}
+
+// hashID returns the hex encoded SHA-256 of a session ID.
+func hashID(id string) string {
+	if id == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(id))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// changedKeys returns the sorted keys whose values differ between old and
+// current. Values are compared by their serialized form so that serializer
+// specific type conversions, e.g. numbers becoming float64 in JSON, are not
+// reported as changes.
+func changedKeys(serializer SessionSerializer, old, current map[interface{}]interface{}) []string {
+	keys := make([]string, 0)
+	seen := make(map[interface{}]struct{}, len(current))
+
+	for k, v := range current {
+		seen[k] = struct{}{}
+
+		prev, ok := old[k]
+		if !ok || !sameValue(serializer, k, prev, v) {
+			keys = append(keys, fmt.Sprint(k))
+		}
+	}
+
+	for k := range old {
+		if _, ok := seen[k]; !ok {
+			keys = append(keys, fmt.Sprint(k))
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sameValue(serializer SessionSerializer, key, a, b interface{}) bool {
+	ea, errA := encodeValue(serializer, key, a)
+	eb, errB := encodeValue(serializer, key, b)
+	if errA != nil || errB != nil {
+		return false
+	}
+
+	return bytes.Equal(ea, eb)
+}
+
+// encodeValue serializes a single session value, so that values can be
+// compared independently of the order of the session values.
+func encodeValue(serializer SessionSerializer, key, v interface{}) ([]byte, error) {
+	return serializer.Serialize(&sessions.Session{ //nolint: exhaustruct
+		Values: map[interface{}]interface{}{key: v},
+	})
+}
@@ -0,0 +1,211 @@
+package redisstore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/gorilla/sessions"
+	"github.com/joelrose/redisstore/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) HandleEvent(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]EventType, 0, len(r.events))
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+
+	return types
+}
+
+func TestEvents(t *testing.T) {
+	newStore := func(t *testing.T) (*Store, *mocks.MockRedisClient, *recorder) {
+		t.Helper()
+
+		mockCtrl := gomock.NewController(t)
+		t.Cleanup(mockCtrl.Finish)
+
+		client := mocks.NewMockRedisClient(mockCtrl)
+		rec := &recorder{}
+
+		store := New(
+			client,
+			[][]byte{[]byte("key")},
+			WithKeyGenerator(func() string { return "id" }),
+			WithKeyPrefix("prefix_"),
+			WithSerializer(JSONSerializer{}),
+			WithEventHandler(rec),
+		)
+
+		return store, client, rec
+	}
+
+	t.Run("created", func(t *testing.T) {
+		store, client, rec := newStore(t)
+
+		client.EXPECT().Get(gomock.Any(), "prefix_id").Return(nil, errors.New("nil"))
+		client.EXPECT().Set(gomock.Any(), "prefix_id", gomock.Any(), gomock.Any()).Return(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session, err := store.New(req, "test")
+		assert.NoError(t, err)
+
+		session.Values["user_id"] = "42"
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+
+		assert.Equal(t, []EventType{SessionCreated}, rec.types())
+		assert.Equal(t, "test", rec.events[0].Name)
+		assert.Equal(t, hashID("id"), rec.events[0].HashedID)
+		assert.NotContains(t, rec.events[0].HashedID, "id")
+		assert.Equal(t, []string{"user_id"}, rec.events[0].Keys)
+		assert.Same(t, req, rec.events[0].Request)
+	})
+
+	t.Run("saved reports changed keys", func(t *testing.T) {
+		store, client, rec := newStore(t)
+
+		client.EXPECT().Get(gomock.Any(), "prefix_id").Return([]byte(`{"count":1,"name":"a","removed":true}`), nil)
+		client.EXPECT().Set(gomock.Any(), "prefix_id", gomock.Any(), gomock.Any()).Return(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session := sessions.NewSession(store, "test")
+		session.Options = &sessions.Options{MaxAge: 60}
+		session.ID = "id"
+		session.Values["count"] = 1
+		session.Values["name"] = "b"
+		session.Values["added"] = "c"
+
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+
+		assert.Equal(t, []EventType{SessionSaved}, rec.types())
+		assert.Equal(t, []string{"added", "name", "removed"}, rec.events[0].Keys)
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		store, client, rec := newStore(t)
+
+		client.EXPECT().Get(gomock.Any(), "prefix_id:aux").Return(nil, ErrNotFound)
+		client.EXPECT().Del(gomock.Any(), "prefix_id").Return(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session := sessions.NewSession(store, "test")
+		session.Options = &sessions.Options{MaxAge: -1}
+		session.ID = "id"
+
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+		assert.Equal(t, []EventType{SessionDeleted}, rec.types())
+	})
+
+	t.Run("rejected invalid cookie", func(t *testing.T) {
+		store, _, rec := newStore(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "test", Value: "forged"})
+
+		_, err := store.New(req, "test")
+		assert.Error(t, err)
+
+		assert.Equal(t, []EventType{SessionRejected}, rec.types())
+		assert.Error(t, rec.events[0].Err)
+	})
+
+	t.Run("rejected unknown session", func(t *testing.T) {
+		store, client, rec := newStore(t)
+
+		encoded, err := store.Codecs[0].Encode("test", "id")
+		assert.NoError(t, err)
+
+		client.EXPECT().Get(gomock.Any(), "prefix_id").Return(nil, errors.New("nil"))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: "test", Value: encoded})
+
+		session, err := store.New(req, "test")
+		assert.NoError(t, err)
+		assert.True(t, session.IsNew)
+
+		assert.Equal(t, []EventType{SessionRejected}, rec.types())
+		assert.Equal(t, hashID("id"), rec.events[0].HashedID)
+	})
+
+	t.Run("regenerate", func(t *testing.T) {
+		store, client, rec := newStore(t)
+		store.keyGen = func() string { return "new" }
+
+		client.EXPECT().Get(gomock.Any(), "prefix_old:aux").Return(nil, ErrNotFound)
+		client.EXPECT().Del(gomock.Any(), "prefix_old").Return(nil)
+		client.EXPECT().Get(gomock.Any(), "prefix_new").Return(nil, errors.New("nil"))
+		client.EXPECT().Set(gomock.Any(), "prefix_new", gomock.Any(), gomock.Any()).Return(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session := sessions.NewSession(store, "test")
+		session.Options = &sessions.Options{MaxAge: 60}
+		session.ID = "old"
+		session.Values["user_id"] = "42"
+
+		assert.NoError(t, store.Regenerate(req, httptest.NewRecorder(), session))
+
+		assert.Equal(t, "new", session.ID)
+		assert.Equal(t, []EventType{SessionDeleted, SessionCreated}, rec.types())
+		assert.Equal(t, hashID("old"), rec.events[0].HashedID)
+		assert.Equal(t, hashID("new"), rec.events[1].HashedID)
+	})
+}
+
+func TestAsyncEventHandler(t *testing.T) {
+	t.Run("delivers events", func(t *testing.T) {
+		rec := &recorder{}
+		handler := NewAsyncEventHandler(rec, 10)
+
+		handler.HandleEvent(Event{Type: SessionCreated})
+		handler.HandleEvent(Event{Type: SessionDeleted})
+		handler.Close()
+
+		assert.Equal(t, []EventType{SessionCreated, SessionDeleted}, rec.types())
+		assert.Equal(t, uint64(0), handler.Dropped())
+	})
+
+	t.Run("drops events when full", func(t *testing.T) {
+		block := make(chan struct{})
+		handler := NewAsyncEventHandler(EventHandlerFunc(func(Event) { <-block }), 1)
+
+		// The first event is picked up by the worker, the second fills the
+		// queue and every following one is dropped.
+		handler.HandleEvent(Event{Type: SessionSaved})
+		for i := 0; i < 10; i++ {
+			handler.HandleEvent(Event{Type: SessionSaved})
+		}
+
+		close(block)
+		handler.Close()
+
+		assert.GreaterOrEqual(t, handler.Dropped(), uint64(8))
+	})
+
+	t.Run("drops events after close", func(t *testing.T) {
+		handler := NewAsyncEventHandler(&recorder{}, 1)
+		handler.Close()
+
+		handler.HandleEvent(Event{Type: SessionSaved})
+		assert.Equal(t, uint64(1), handler.Dropped())
+	})
+}
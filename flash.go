@@ -0,0 +1,117 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// flashKeyName is the name of the auxiliary keys that store flash messages.
+const flashKeyName = "flash"
+
+// AddFlash stores a flash message of a category for a following request of
+// the session, e.g. after a redirect. Unlike sessions.Session.AddFlash, the
+// message is written next to the session immediately and does not need a
+// Save. Sessions without an ID are saved first. Flash messages expire with
+// the session and are deleted with it, also by Regenerate.
+//
+// Messages added concurrently to the same category of a session may be lost.
+func (s *Store) AddFlash(r *http.Request, w http.ResponseWriter, session *sessions.Session, category, message string) error {
+	if session.Options.MaxAge <= 0 {
+		return errors.New("redisstore(flash): session is deleted")
+	}
+
+	if session.ID == "" {
+		if err := s.Save(r, w, session); err != nil {
+			return err
+		}
+	}
+
+	var messages []string
+	val, err := s.client.Get(r.Context(), s.flashKey(session.ID, category))
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("redisstore(flash): getting flashes: %v", err)
+	default:
+		if err := json.Unmarshal(val, &messages); err != nil {
+			return fmt.Errorf("redisstore(flash): decoding flashes: %v", err)
+		}
+	}
+
+	b, err := json.Marshal(append(messages, message))
+	if err != nil {
+		return fmt.Errorf("redisstore(flash): encoding flashes: %v", err)
+	}
+
+	maxAge := time.Duration(session.Options.MaxAge) * time.Second
+	if err := s.setAux(r.Context(), session.ID, flashName(category), b, maxAge); err != nil {
+		return fmt.Errorf("redisstore(flash): setting flashes: %v", err)
+	}
+
+	return nil
+}
+
+// Flashes returns the flash messages of a category in the order they were
+// added and deletes them, so that every message is returned exactly once. No
+// Save is needed.
+//
+// Messages are read and deleted atomically with GETDEL if the client
+// implements GetDeleter and the server supports it, see Capabilities.GetDel.
+// Otherwise, concurrent requests of the same session may both return a
+// message.
+func (s *Store) Flashes(r *http.Request, session *sessions.Session, category string) ([]string, error) {
+	if session.ID == "" {
+		return nil, nil
+	}
+
+	val, err := s.getDel(r.Context(), s.flashKey(session.ID, category))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redisstore(flash): consuming flashes: %v", err)
+	}
+
+	var messages []string
+	if err := json.Unmarshal(val, &messages); err != nil {
+		return nil, fmt.Errorf("redisstore(flash): decoding flashes: %v", err)
+	}
+
+	return messages, nil
+}
+
+// flashKey returns the key of the flash messages of a category.
+func (s *Store) flashKey(id, category string) string {
+	return s.auxKey(id, flashName(category))
+}
+
+// flashName returns the auxiliary key name of the flash messages of a
+// category.
+func flashName(category string) string {
+	return flashKeyName + auxKeySeparator + category
+}
+
+// getDel reads and deletes a key, atomically if the client and the server
+// support GETDEL.
+func (s *Store) getDel(ctx context.Context, key string) ([]byte, error) {
+	if deleter, ok := s.client.(GetDeleter); ok && s.Capabilities().GetDel {
+		return deleter.GetDel(ctx, key) //nolint: wrapcheck
+	}
+
+	val, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err := s.client.Del(ctx, key); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return val, nil
+}
@@ -0,0 +1,116 @@
+package redisstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getDelClient is a scanClient implementing GetDeleter that counts its calls.
+type getDelClient struct {
+	scanClient
+	getDels int
+}
+
+func (c *getDelClient) GetDel(_ context.Context, key string) ([]byte, error) {
+	c.getDels++
+
+	val, ok := c.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(c.data, key)
+
+	return val, nil
+}
+
+func TestStoreFlashes(t *testing.T) {
+	tests := []struct {
+		name    string
+		caps    Capabilities
+		getDels int
+	}{
+		{"getdel", defaultCapabilities, 3},
+		{"fallback", Capabilities{GetDel: false}, 0}, //nolint: exhaustruct
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &getDelClient{scanClient: scanClient{data: map[string][]byte{}}}
+			store := New(client, [][]byte{[]byte("key")}, WithCapabilities(tt.caps))
+
+			// Adding a flash to a new session saves it.
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			res := httptest.NewRecorder()
+			session, _ := store.New(req, "session")
+			assert.NoError(t, store.AddFlash(req, res, session, "info", "saved"))
+			assert.NoError(t, store.AddFlash(req, res, session, "info", "again"))
+			assert.NoError(t, store.AddFlash(req, res, session, "error", "failed"))
+			assert.Len(t, res.Result().Cookies(), 1)
+			assert.Contains(t, client.data, "session_"+session.ID+":flash:info")
+
+			// The redirected request consumes the flashes without saving.
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(res.Result().Cookies()[0])
+			session, err := store.New(req, "session")
+			assert.NoError(t, err)
+			assert.False(t, session.IsNew)
+
+			flashes, err := store.Flashes(req, session, "info")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"saved", "again"}, flashes)
+
+			flashes, err = store.Flashes(req, session, "info")
+			assert.NoError(t, err)
+			assert.Empty(t, flashes)
+
+			flashes, err = store.Flashes(req, session, "error")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"failed"}, flashes)
+
+			// Only the session and the index of its auxiliary keys remain.
+			assert.Len(t, client.data, 2)
+			assert.Equal(t, tt.getDels, client.getDels)
+		})
+	}
+
+	t.Run("session without id", func(t *testing.T) {
+		store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session, _ := store.New(req, "session")
+
+		flashes, err := store.Flashes(req, session, "info")
+		assert.NoError(t, err)
+		assert.Empty(t, flashes)
+	})
+
+	t.Run("deleted session", func(t *testing.T) {
+		store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		session, _ := store.New(req, "session")
+		session.Options.MaxAge = -1
+
+		assert.Error(t, store.AddFlash(req, httptest.NewRecorder(), session, "info", "saved"))
+	})
+
+	t.Run("deleted with the session", func(t *testing.T) {
+		client := &scanClient{data: map[string][]byte{}}
+		store := New(client, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		session, _ := store.New(req, "session")
+		assert.NoError(t, store.AddFlash(req, res, session, "info", "saved"))
+		assert.NoError(t, store.AddFlash(req, res, session, "error", "failed"))
+
+		// Regenerate drops the flashes of the old ID.
+		assert.NoError(t, store.Regenerate(req, res, session))
+		assert.Len(t, client.data, 1)
+
+		assert.NoError(t, store.AddFlash(req, res, session, "info", "saved"))
+		session.Options.MaxAge = -1
+		assert.NoError(t, store.Save(req, res, session))
+		assert.Empty(t, client.data)
+	})
+}
@@ -7,9 +7,12 @@ require (
 	github.com/gomodule/redigo v1.8.9
 	github.com/gorilla/securecookie v1.1.1
 	github.com/gorilla/sessions v1.2.1
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/redis/go-redis/v9 v9.0.2
+	github.com/redis/rueidis v1.0.19
 	github.com/rs/xid v1.4.0
 	github.com/stretchr/testify v1.8.2
+	go.etcd.io/bbolt v1.3.7
 )
 
 require (
@@ -17,5 +20,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,242 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// impersonationValueKey is the session value that marks an impersonated
+// session.
+const impersonationValueKey = "_redisstore_impersonation"
+
+// impersonatorKeyName is the name of the auxiliary key that stores the
+// original session of an impersonation.
+const impersonatorKeyName = "impersonator"
+
+var (
+	// ErrImpersonating is returned by Store.Impersonate, Store.Regenerate and
+	// Store.Promote if the session impersonates another user.
+	ErrImpersonating = errors.New("redisstore: session is already impersonating")
+	// ErrNotImpersonating is returned by Store.StopImpersonation if the session
+	// does not impersonate another user.
+	ErrNotImpersonating = errors.New("redisstore: session is not impersonating")
+	// ErrImpersonationExpired is returned by Store.StopImpersonation if the
+	// original session no longer exists.
+	ErrImpersonationExpired = errors.New("redisstore: impersonation expired")
+)
+
+// Impersonation describes a session in which a user, e.g. a support agent,
+// acts as another user.
+type Impersonation struct {
+	// Impersonator identifies the user that started the impersonation.
+	Impersonator string `json:"impersonator"`
+	// Subject identifies the impersonated user.
+	Subject string `json:"subject"`
+	// Reason is an optional justification for the audit trail.
+	Reason string `json:"reason,omitempty"`
+	// Started and Expires are set by Store.Impersonate.
+	Started time.Time `json:"started"`
+	Expires time.Time `json:"expires"`
+}
+
+// impersonatorState is the original session stored during an impersonation.
+type impersonatorState struct {
+	MaxAge  int    `json:"max_age"`
+	Session []byte `json:"session"`
+}
+
+// Impersonate replaces a saved session with a session of another user for at
+// most ttl. The session gets a new ID and values, and is marked with
+// impersonation. The original session is moved to a separate key and is
+// restored by StopImpersonation.
+//
+// The impersonated session, including its cookie, expires with the
+// impersonation and can not be extended by saving it. Requests with an expired
+// impersonation get a new session. Every transition emits an Impersonation*
+// event to the handlers registered with WithEventHandler.
+//
+// The original session is bound to the ID of the impersonated session, so
+// Regenerate and Promote return ErrImpersonating until the impersonation is
+// stopped. Deleting the impersonated session, including by BindingRegenerate,
+// deletes the original session as well.
+func (s *Store) Impersonate(
+	r *http.Request,
+	w http.ResponseWriter,
+	session *sessions.Session,
+	impersonation Impersonation,
+	ttl time.Duration,
+	values map[interface{}]interface{},
+) error {
+	if session.ID == "" || session.Options.MaxAge <= 0 {
+		return errors.New("redisstore(impersonate): session is not saved")
+	}
+	if ttl < time.Second {
+		return errors.New("redisstore(impersonate): ttl must be at least one second")
+	}
+	if _, ok := Impersonating(session); ok {
+		return ErrImpersonating
+	}
+
+	original, err := s.serializer.Serialize(session)
+	if err != nil {
+		return fmt.Errorf("redisstore(impersonate): serializing session: %v", err)
+	}
+
+	state, err := json.Marshal(impersonatorState{MaxAge: session.Options.MaxAge, Session: original})
+	if err != nil {
+		return fmt.Errorf("redisstore(impersonate): encoding original session: %v", err)
+	}
+
+	impersonation.Started = time.Now()
+	impersonation.Expires = impersonation.Started.Add(ttl)
+
+	marker, err := json.Marshal(impersonation)
+	if err != nil {
+		return fmt.Errorf("redisstore(impersonate): encoding impersonation: %v", err)
+	}
+
+	impersonated := *session
+	impersonated.ID = s.keyGen()
+	impersonated.Values = make(map[interface{}]interface{}, len(values)+1)
+	for k, v := range values {
+		impersonated.Values[k] = v
+	}
+	impersonated.Values[impersonationValueKey] = string(marker)
+
+	options := *session.Options
+	options.MaxAge = int(math.Ceil(ttl.Seconds()))
+	impersonated.Options = &options
+
+	s.bind(r, &impersonated)
+
+	if err := s.setAux(r.Context(), impersonated.ID, impersonatorKeyName, state, ttl); err != nil {
+		return fmt.Errorf("redisstore(impersonate): setting original session: %v", err)
+	}
+
+	if err := s.save(r.Context(), &impersonated); err != nil {
+		return fmt.Errorf("redisstore(impersonate): saving session: %v", err)
+	}
+
+	if err := s.delete(r.Context(), session); err != nil {
+		return fmt.Errorf("redisstore(impersonate): deleting session: %v", err)
+	}
+	s.emit(SessionDeleted, r, session, nil, nil)
+
+	*session = impersonated
+
+	if err := s.saved(r, w, session, nil); err != nil {
+		return err
+	}
+
+	s.emitImpersonation(ImpersonationStarted, r, session, nil, nil, &impersonation)
+
+	return nil
+}
+
+// StopImpersonation restores the original session of an impersonation under a
+// new ID and deletes the impersonated session. If the original session
+// expired, the impersonated session is deleted and ErrImpersonationExpired is
+// returned.
+func (s *Store) StopImpersonation(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	impersonation, ok := Impersonating(session)
+	if !ok || session.ID == "" {
+		return ErrNotImpersonating
+	}
+
+	val, err := s.getDel(r.Context(), s.auxKey(session.ID, impersonatorKeyName))
+	if errors.Is(err, ErrNotFound) {
+		session.Options.MaxAge = -1
+		if err := s.Save(r, w, session); err != nil {
+			return fmt.Errorf("redisstore(impersonate): %v", err)
+		}
+		s.emitImpersonation(ImpersonationExpired, r, session, nil, nil, &impersonation)
+
+		return ErrImpersonationExpired
+	}
+	if err != nil {
+		return fmt.Errorf("redisstore(impersonate): getting original session: %v", err)
+	}
+
+	var state impersonatorState
+	if err := json.Unmarshal(val, &state); err != nil {
+		return fmt.Errorf("redisstore(impersonate): decoding original session: %v", err)
+	}
+
+	if err := s.delete(r.Context(), session); err != nil {
+		return fmt.Errorf("redisstore(impersonate): deleting session: %v", err)
+	}
+	s.emit(SessionDeleted, r, session, nil, nil)
+
+	session.Values = make(map[interface{}]interface{})
+	if err := s.deserialize(state.Session, session); err != nil {
+		return fmt.Errorf("redisstore(impersonate): %v", err)
+	}
+
+	session.Options.MaxAge = state.MaxAge
+	session.ID = s.keyGen()
+
+	if err := s.save(r.Context(), session); err != nil {
+		return fmt.Errorf("redisstore(impersonate): saving session: %v", err)
+	}
+
+	if err := s.saved(r, w, session, nil); err != nil {
+		return err
+	}
+
+	s.emitImpersonation(ImpersonationStopped, r, session, nil, nil, &impersonation)
+
+	return nil
+}
+
+// Impersonating returns the impersonation of a session and reports whether
+// the session is impersonating another user.
+func Impersonating(session *sessions.Session) (Impersonation, bool) {
+	var impersonation Impersonation
+
+	marker, ok := session.Values[impersonationValueKey].(string)
+	if !ok {
+		return impersonation, false
+	}
+
+	if err := json.Unmarshal([]byte(marker), &impersonation); err != nil {
+		return impersonation, false
+	}
+
+	return impersonation, true
+}
+
+// checkImpersonation replaces a loaded session whose impersonation expired by
+// a new session. It reports whether the loaded session is used.
+func (s *Store) checkImpersonation(r *http.Request, session *sessions.Session) bool {
+	impersonation, ok := Impersonating(session)
+	if !ok || time.Now().Before(impersonation.Expires) {
+		return true
+	}
+
+	s.emitImpersonation(ImpersonationExpired, r, session, nil, nil, &impersonation)
+
+	session.Values = make(map[interface{}]interface{})
+	session.ID = ""
+
+	return false
+}
+
+// capImpersonation limits the max age of an impersonated session to the
+// remaining time of the impersonation, so that saving it does not extend it.
+func capImpersonation(session *sessions.Session) {
+	impersonation, ok := Impersonating(session)
+	if !ok || session.Options.MaxAge <= 0 {
+		return
+	}
+
+	maxAge := int(math.Ceil(time.Until(impersonation.Expires).Seconds()))
+	if maxAge < session.Options.MaxAge {
+		session.Options.MaxAge = maxAge
+	}
+}
@@ -0,0 +1,153 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreImpersonation(t *testing.T) {
+	newAdminSession := func(t *testing.T) (*Store, *scanClient, *recorder, *http.Request) {
+		t.Helper()
+
+		client := &scanClient{data: map[string][]byte{}}
+		rec := &recorder{}
+		store := New(client, [][]byte{[]byte("key")}, WithEventHandler(rec))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		session, _ := store.New(req, "session")
+		session.Values["user"] = "admin"
+		assert.NoError(t, store.Save(req, res, session))
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(res.Result().Cookies()[0])
+		rec.events = nil
+
+		return store, client, rec, req
+	}
+
+	t.Run("impersonate and stop", func(t *testing.T) {
+		store, client, rec, req := newAdminSession(t)
+
+		session, _ := store.New(req, "session")
+		adminID := session.ID
+
+		res := httptest.NewRecorder()
+		err := store.Impersonate(req, res, session, Impersonation{Impersonator: "admin", Subject: "alice", Reason: "ticket"},
+			time.Hour, map[interface{}]interface{}{"user": "alice"})
+		assert.NoError(t, err)
+		assert.NotEqual(t, adminID, session.ID)
+		assert.Equal(t, "alice", session.Values["user"])
+		assert.Equal(t, 3600, session.Options.MaxAge)
+		assert.NotContains(t, client.data, store.SessionKey(adminID))
+		assert.Contains(t, client.data, store.SessionKey(session.ID)+":impersonator")
+		assert.Equal(t, []EventType{SessionDeleted, SessionCreated, ImpersonationStarted}, rec.types())
+		assert.Equal(t, "alice", rec.events[2].Impersonation.Subject)
+
+		assert.ErrorIs(t, store.Impersonate(req, res, session, Impersonation{}, time.Hour, nil), ErrImpersonating)
+
+		// The impersonated session is loaded with its cookie.
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(res.Result().Cookies()[0])
+		session, err = store.New(req, "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+
+		impersonation, ok := Impersonating(session)
+		assert.True(t, ok)
+		assert.Equal(t, "admin", impersonation.Impersonator)
+		assert.Equal(t, "ticket", impersonation.Reason)
+
+		// Saving does not extend the impersonation.
+		session.Options.MaxAge = defaultMaxAge
+		assert.NoError(t, store.Save(req, httptest.NewRecorder(), session))
+		assert.LessOrEqual(t, session.Options.MaxAge, 3600)
+
+		rec.events = nil
+		res = httptest.NewRecorder()
+		assert.NoError(t, store.StopImpersonation(req, res, session))
+		assert.Equal(t, "admin", session.Values["user"])
+		assert.Equal(t, defaultMaxAge, session.Options.MaxAge)
+		assert.Len(t, client.data, 1)
+		assert.Equal(t, []EventType{SessionDeleted, SessionCreated, ImpersonationStopped}, rec.types())
+
+		_, ok = Impersonating(session)
+		assert.False(t, ok)
+		assert.ErrorIs(t, store.StopImpersonation(req, res, session), ErrNotImpersonating)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		store, client, rec, req := newAdminSession(t)
+
+		session, _ := store.New(req, "session")
+		res := httptest.NewRecorder()
+		assert.NoError(t, store.Impersonate(req, res, session, Impersonation{Subject: "alice"}, time.Minute, nil))
+
+		// Move the expiry of the stored marker into the past.
+		impersonation, _ := Impersonating(session)
+		impersonation.Expires = time.Now().Add(-time.Second)
+		marker, _ := json.Marshal(impersonation)
+		session.Values[impersonationValueKey] = string(marker)
+		assert.NoError(t, store.save(req.Context(), session))
+
+		rec.events = nil
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(res.Result().Cookies()[0])
+		loaded, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.True(t, loaded.IsNew)
+		assert.Empty(t, loaded.ID)
+		assert.Empty(t, loaded.Values)
+		assert.Equal(t, []EventType{ImpersonationExpired}, rec.types())
+
+		// Stopping fails once the original session is gone.
+		delete(client.data, store.SessionKey(session.ID)+":impersonator")
+		assert.ErrorIs(t, store.StopImpersonation(req, httptest.NewRecorder(), session), ErrImpersonationExpired)
+		assert.Empty(t, client.data)
+	})
+
+	t.Run("regenerate and promote", func(t *testing.T) {
+		store, client, _, req := newAdminSession(t)
+
+		session, _ := store.New(req, "session")
+		res := httptest.NewRecorder()
+		assert.NoError(t, store.Impersonate(req, res, session, Impersonation{Subject: "alice"}, time.Hour, nil))
+		id := session.ID
+
+		// The original session stays reachable from the impersonated ID.
+		assert.ErrorIs(t, store.Regenerate(req, res, session), ErrImpersonating)
+		other, _ := store.New(httptest.NewRequest(http.MethodGet, "/", nil), "other")
+		assert.ErrorIs(t, store.Promote(req, res, session, other, nil), ErrImpersonating)
+		assert.ErrorIs(t, store.Promote(req, res, other, session, nil), ErrImpersonating)
+		assert.Equal(t, id, session.ID)
+
+		assert.NoError(t, store.StopImpersonation(req, httptest.NewRecorder(), session))
+		assert.Equal(t, "admin", session.Values["user"])
+		assert.Len(t, client.data, 1)
+	})
+
+	t.Run("deleted with the session", func(t *testing.T) {
+		store, client, _, req := newAdminSession(t)
+
+		session, _ := store.New(req, "session")
+		res := httptest.NewRecorder()
+		assert.NoError(t, store.Impersonate(req, res, session, Impersonation{Subject: "alice"}, time.Hour, nil))
+
+		session.Options.MaxAge = -1
+		assert.NoError(t, store.Save(req, res, session))
+		assert.Empty(t, client.data)
+	})
+
+	t.Run("unsaved session", func(t *testing.T) {
+		store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		session, _ := store.New(req, "session")
+		assert.Error(t, store.Impersonate(req, httptest.NewRecorder(), session, Impersonation{}, time.Hour, nil))
+	})
+}
@@ -0,0 +1,92 @@
+// Package glob matches keys against the glob patterns of the redis commands
+// SCAN and KEYS for clients that are not backed by redis.
+package glob
+
+// Match reports whether key matches pattern with the rules of redis: "*"
+// matches any sequence of bytes including "/", "?" matches a single byte,
+// "[abc]", "[a-z]" and "[^abc]" match a single byte of a class and "\"
+// escapes the following byte. Patterns and keys are compared byte by byte.
+func Match(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+
+			for i := 0; i <= len(key); i++ {
+				if Match(pattern[1:], key[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+
+			var ok bool
+			pattern, ok = matchClass(pattern[1:], key[0])
+			if !ok {
+				return false
+			}
+			key = key[1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || pattern[0] != key[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+
+	return len(key) == 0
+}
+
+// matchClass matches b against the character class at the start of pattern,
+// after the opening "[". It returns the pattern after the closing "]". Like
+// redis, an unterminated class ends with the pattern.
+func matchClass(pattern string, b byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	match := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			match = match || pattern[1] == b
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-':
+			start, end := pattern[0], pattern[2]
+			if start > end {
+				start, end = end, start
+			}
+			match = match || (b >= start && b <= end)
+			pattern = pattern[3:]
+		default:
+			match = match || pattern[0] == b
+			pattern = pattern[1:]
+		}
+	}
+
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+
+	return pattern, match != negate
+}
@@ -0,0 +1,50 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"session_*", "session_abc", true},
+		{"session_*", "session_a/b", true},
+		{"session_*", "cache_abc", false},
+		{"*_abc", "session_abc", true},
+		{"s*n*c", "session_abc", true},
+		{"s**c", "session_abc", true},
+		{"session_?", "session_a", true},
+		{"session_?", "session_", false},
+		{"session_?", "session_/", true},
+		{"session_[ab]", "session_b", true},
+		{"session_[ab]", "session_c", false},
+		{"session_[^ab]", "session_c", true},
+		{"session_[^ab]", "session_a", false},
+		{"session_[a-c]", "session_b", true},
+		{"session_[c-a]", "session_b", true},
+		{"session_[a-c]", "session_d", false},
+		{"session_[\\]]", "session_]", true},
+		{"session_[a", "session_a", true},
+		{"session_\\*", "session_*", true},
+		{"session_\\*", "session_a", false},
+		{"session_\\?", "session_?", true},
+		{"session_\\[a]", "session_[a]", true},
+		{"session_{\\*}*", "session_{*}:flash", true},
+		{"session_{a*}", "session_{ab}", true},
+		{"session_{a*}", "session_{ab}:flash", false},
+		{"session_\\", "session_\\", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.pattern, tt.key))
+		})
+	}
+}
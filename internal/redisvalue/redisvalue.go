@@ -0,0 +1,37 @@
+// Package redisvalue converts values passed to redisstore.Client.Set for
+// clients that are not backed by redis.
+package redisvalue
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Bytes converts a value to the bytes go-redis would store for it: byte slices
+// and strings are stored as they are, numbers in their decimal form without an
+// exponent, booleans as "1" and "0" and nil as an empty value. The returned
+// slice never aliases value.
+func Bytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return append([]byte(nil), v...), nil
+	case string:
+		return []byte(v), nil
+	case nil:
+		return []byte{}, nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+
+		return []byte("0"), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 64), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return []byte(fmt.Sprint(v)), nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+}
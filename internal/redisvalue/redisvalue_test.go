@@ -0,0 +1,43 @@
+package redisvalue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  []byte
+	}{
+		{"bytes", []byte("value"), []byte("value")},
+		{"string", "value", []byte("value")},
+		{"nil", nil, []byte{}},
+		{"int", 42, []byte("42")},
+		{"float", 1.5, []byte("1.5")},
+		{"large float", 1e21, []byte("1000000000000000000000")},
+		{"true", true, []byte("1")},
+		{"false", false, []byte("0")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Bytes(tt.value)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, b)
+		})
+	}
+
+	t.Run("copy", func(t *testing.T) {
+		value := []byte("value")
+		b, err := Bytes(value)
+		assert.NoError(t, err)
+
+		value[0] = 'V'
+		assert.Equal(t, []byte("value"), b)
+	})
+
+	_, err := Bytes(struct{}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,335 @@
+package redisstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+// SigningKey is a named securecookie key pair.
+type SigningKey struct {
+	// ID identifies the key in metrics and key files.
+	ID string
+	// HashKey authenticates cookie values. It is required.
+	HashKey []byte
+	// BlockKey encrypts cookie values if set. It must be 16, 24 or 32 bytes
+	// long.
+	BlockKey []byte
+}
+
+// KeyStats are the metrics of a single key of a KeyRing.
+type KeyStats struct {
+	ID     string `json:"id"`
+	Active bool   `json:"active"`
+	// Decoded is the number of cookies the key decoded.
+	Decoded uint64 `json:"decoded"`
+}
+
+// KeyRingStats are the metrics of a KeyRing.
+type KeyRingStats struct {
+	Keys []KeyStats `json:"keys"`
+	// Failed is the number of cookies that no key could decode.
+	Failed uint64 `json:"failed"`
+}
+
+// KeyRing holds the key that signs cookies and the keys that are still
+// accepted when verifying them. Keys can be rotated while the store is in use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	keys    []ringKey // the active key comes first
+	maxAge  int
+	decoded map[string]*uint64
+	failed  uint64 // accessed atomically
+}
+
+var _ CookieCodec = (*KeyRing)(nil)
+
+// ringKey is a SigningKey with its codec.
+type ringKey struct {
+	id    string
+	codec *securecookie.SecureCookie
+}
+
+// NewKeyRing returns a KeyRing that signs cookies with active and accepts
+// cookies signed with any of the verify keys.
+func NewKeyRing(active SigningKey, verify ...SigningKey) (*KeyRing, error) {
+	k := &KeyRing{
+		mu:      sync.RWMutex{},
+		keys:    nil,
+		maxAge:  defaultMaxAge,
+		decoded: make(map[string]*uint64),
+		failed:  0,
+	}
+
+	if err := k.Rotate(active, verify...); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Rotate replaces the keys of the ring. Metrics of keys that remain in the
+// ring are kept. If a key is invalid, the ring keeps its previous keys.
+func (k *KeyRing) Rotate(active SigningKey, verify ...SigningKey) error {
+	all := append([]SigningKey{active}, verify...)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	keys := make([]ringKey, 0, len(all))
+	decoded := make(map[string]*uint64, len(all))
+	for _, key := range all {
+		if key.ID == "" || len(key.HashKey) == 0 {
+			return errors.New("redisstore(keyring): key id and hash key are required")
+		}
+		switch len(key.BlockKey) {
+		case 0, 16, 24, 32:
+		default:
+			return fmt.Errorf("redisstore(keyring): block key of key %q must be 16, 24 or 32 bytes long", key.ID)
+		}
+		if _, ok := decoded[key.ID]; ok {
+			return fmt.Errorf("redisstore(keyring): duplicate key id %q", key.ID)
+		}
+
+		codec := securecookie.New(key.HashKey, key.BlockKey)
+		codec.MaxAge(k.maxAge)
+
+		keys = append(keys, ringKey{id: key.ID, codec: codec})
+
+		decoded[key.ID] = new(uint64)
+		if counter, ok := k.decoded[key.ID]; ok {
+			decoded[key.ID] = counter
+		}
+	}
+
+	k.keys = keys
+	k.decoded = decoded
+
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key that signs cookies.
+func (k *KeyRing) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys[0].id
+}
+
+// Stats returns the metrics of all keys, the active key first.
+func (k *KeyRing) Stats() KeyRingStats {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	stats := KeyRingStats{
+		Keys:   make([]KeyStats, 0, len(k.keys)),
+		Failed: atomic.LoadUint64(&k.failed),
+	}
+	for i, key := range k.keys {
+		stats.Keys = append(stats.Keys, KeyStats{
+			ID:      key.id,
+			Active:  i == 0,
+			Decoded: atomic.LoadUint64(k.decoded[key.id]),
+		})
+	}
+
+	return stats
+}
+
+// SetMaxAge sets the max age in seconds of the cookies the keys accept. It is
+// called by Store.SetMaxAge.
+func (k *KeyRing) SetMaxAge(age int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.maxAge = age
+	for _, key := range k.keys {
+		key.codec.MaxAge(age)
+	}
+}
+
+// Encode implements CookieCodec. It signs the session ID with the active key.
+func (k *KeyRing) Encode(name, id string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys[0].codec.Encode(name, id) //nolint: wrapcheck
+}
+
+// Decode implements CookieCodec.
+func (k *KeyRing) Decode(name, value string) (string, error) {
+	var id string
+	if _, err := k.DecodeWithKey(name, value, &id); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// DecodeWithKey verifies a cookie value with every key of the ring, decodes it
+// into dst and returns the ID of the key that matched.
+func (k *KeyRing) DecodeWithKey(name, value string, dst interface{}) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var errs securecookie.MultiError
+	for _, key := range k.keys {
+		err := key.codec.Decode(name, value, dst)
+		if err == nil {
+			atomic.AddUint64(k.decoded[key.id], 1)
+			return key.id, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	atomic.AddUint64(&k.failed, 1)
+
+	return "", errs
+}
+
+// keyFile is the JSON format of key files and variables. Keys are base64
+// encoded.
+type keyFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID       string `json:"id"`
+		HashKey  string `json:"hash_key"`
+		BlockKey string `json:"block_key,omitempty"`
+	} `json:"keys"`
+}
+
+// ParseKeys parses keys in the JSON format
+//
+//	{
+//	  "active": "2024-02",
+//	  "keys": [
+//	    {"id": "2024-02", "hash_key": "<base64>", "block_key": "<base64>"},
+//	    {"id": "2024-01", "hash_key": "<base64>"}
+//	  ]
+//	}
+//
+// and returns the active key and the verify keys.
+func ParseKeys(data []byte) (SigningKey, []SigningKey, error) {
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return SigningKey{}, nil, fmt.Errorf("redisstore(keyring): parsing keys: %v", err) //nolint: exhaustruct
+	}
+
+	var (
+		active    SigningKey
+		hasActive bool
+		verify    []SigningKey
+	)
+	for _, k := range f.Keys {
+		hashKey, err := base64.StdEncoding.DecodeString(k.HashKey)
+		if err != nil {
+			return SigningKey{}, nil, fmt.Errorf("redisstore(keyring): decoding hash key %q: %v", k.ID, err) //nolint: exhaustruct
+		}
+
+		blockKey, err := base64.StdEncoding.DecodeString(k.BlockKey)
+		if err != nil {
+			return SigningKey{}, nil, fmt.Errorf("redisstore(keyring): decoding block key %q: %v", k.ID, err) //nolint: exhaustruct
+		}
+		if len(blockKey) == 0 {
+			blockKey = nil
+		}
+
+		key := SigningKey{ID: k.ID, HashKey: hashKey, BlockKey: blockKey}
+		if k.ID == f.Active {
+			active, hasActive = key, true
+			continue
+		}
+
+		verify = append(verify, key)
+	}
+
+	if !hasActive {
+		return SigningKey{}, nil, fmt.Errorf("redisstore(keyring): active key %q not found", f.Active) //nolint: exhaustruct
+	}
+
+	return active, verify, nil
+}
+
+// LoadKeyRingFile returns a KeyRing with the keys of a JSON file, see
+// ParseKeys.
+func LoadKeyRingFile(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("redisstore(keyring): reading key file: %v", err)
+	}
+
+	active, verify, err := ParseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyRing(active, verify...)
+}
+
+// LoadKeyRingEnv returns a KeyRing with the keys of the environment variable
+// name in the JSON format of ParseKeys.
+func LoadKeyRingEnv(name string) (*KeyRing, error) {
+	data, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("redisstore(keyring): environment variable %s is not set", name)
+	}
+
+	active, verify, err := ParseKeys([]byte(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeyRing(active, verify...)
+}
+
+// WatchFile reloads the keys from a JSON file every interval until ctx is
+// done. Invalid files keep the current keys and are reported to onError, which
+// may be nil.
+func (k *KeyRing) WatchFile(ctx context.Context, path string, interval time.Duration, onError func(err error)) {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			onError(fmt.Errorf("redisstore(keyring): reading key file: %v", err))
+			continue
+		}
+
+		if bytes.Equal(data, last) {
+			continue
+		}
+
+		active, verify, err := ParseKeys(data)
+		if err == nil {
+			err = k.Rotate(active, verify...)
+		}
+		if err != nil {
+			onError(err)
+			continue
+		}
+
+		last = data
+	}
+}
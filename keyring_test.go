@@ -0,0 +1,201 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	_, err := NewKeyRing(SigningKey{ID: "", HashKey: []byte("hash")})
+	assert.Error(t, err)
+
+	_, err = NewKeyRing(SigningKey{ID: "a", HashKey: nil})
+	assert.Error(t, err)
+
+	_, err = NewKeyRing(SigningKey{ID: "a", HashKey: []byte("hash")}, SigningKey{ID: "a", HashKey: []byte("other")})
+	assert.ErrorContains(t, err, "duplicate key id")
+
+	_, err = NewKeyRing(SigningKey{ID: "a", HashKey: []byte("hash"), BlockKey: []byte("short")})
+	assert.ErrorContains(t, err, "block key")
+
+	ring, err := NewKeyRing(SigningKey{ID: "a", HashKey: []byte("hash")})
+	assert.NoError(t, err)
+	assert.Equal(t, "a", ring.ActiveKeyID())
+
+	// An invalid key keeps the previous keys active.
+	err = ring.Rotate(SigningKey{ID: "b", HashKey: []byte("hash"), BlockKey: []byte("short")})
+	assert.ErrorContains(t, err, "block key")
+	assert.Equal(t, "a", ring.ActiveKeyID())
+
+	encoded, err := ring.Encode("session", "value")
+	assert.NoError(t, err)
+
+	decoded, err := ring.Decode("session", encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", decoded)
+}
+
+func TestStoreWithKeyRing(t *testing.T) {
+	oldKey := SigningKey{ID: "old", HashKey: []byte("old-hash")}
+	newKey := SigningKey{ID: "new", HashKey: []byte("new-hash")}
+
+	client := &scanClient{data: map[string][]byte{}}
+
+	// A session saved before the rotation.
+	oldRing, err := NewKeyRing(oldKey)
+	assert.NoError(t, err)
+	store := New(client, nil, WithKeyRing(oldRing))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	session, _ := store.New(req, "session")
+	session.Values["user"] = "a"
+	assert.NoError(t, store.Save(req, res, session))
+	oldCookie := res.Result().Cookies()[0]
+
+	ring, err := NewKeyRing(newKey, oldKey)
+	assert.NoError(t, err)
+	store = New(client, nil, WithKeyRing(ring))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(oldCookie)
+	session, err = store.New(req, "session")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, "a", session.Values["user"])
+
+	// Saving re-signs the cookie with the active key.
+	res = httptest.NewRecorder()
+	assert.NoError(t, store.Save(req, res, session))
+	newCookie := res.Result().Cookies()[0]
+
+	var id string
+	keyID, err := ring.DecodeWithKey("session", newCookie.Value, &id)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", keyID)
+	assert.Equal(t, session.ID, id)
+
+	_, err = oldRing.DecodeWithKey("session", newCookie.Value, &id)
+	assert.Error(t, err)
+
+	_, err = ring.DecodeWithKey("session", "garbage", &id)
+	assert.Error(t, err)
+
+	assert.Equal(t, KeyRingStats{
+		Keys: []KeyStats{
+			{ID: "new", Active: true, Decoded: 1},
+			{ID: "old", Active: false, Decoded: 1},
+		},
+		Failed: 1,
+	}, ring.Stats())
+
+	// Dropping the old key rejects its cookies but keeps the counters.
+	assert.NoError(t, ring.Rotate(newKey))
+	_, err = ring.DecodeWithKey("session", oldCookie.Value, &id)
+	assert.Error(t, err)
+	assert.Equal(t, KeyRingStats{
+		Keys:   []KeyStats{{ID: "new", Active: true, Decoded: 1}},
+		Failed: 2,
+	}, ring.Stats())
+}
+
+func TestKeyRingMaxAge(t *testing.T) {
+	ring, err := NewKeyRing(SigningKey{ID: "a", HashKey: []byte("hash")})
+	assert.NoError(t, err)
+
+	encoded, err := ring.Encode("session", "id")
+	assert.NoError(t, err)
+
+	var id string
+	ring.SetMaxAge(-1)
+	_, err = ring.DecodeWithKey("session", encoded, &id)
+	assert.Error(t, err)
+
+	New(nil, nil, WithKeyRing(ring))
+	_, err = ring.DecodeWithKey("session", encoded, &id)
+	assert.NoError(t, err)
+}
+
+func keyFileJSON(active string, ids ...string) string {
+	keys := ""
+	for i, id := range ids {
+		if i > 0 {
+			keys += ","
+		}
+		hash := base64.StdEncoding.EncodeToString([]byte("hash-" + id))
+		keys += fmt.Sprintf(`{"id": %q, "hash_key": %q}`, id, hash)
+	}
+
+	return fmt.Sprintf(`{"active": %q, "keys": [%s]}`, active, keys)
+}
+
+func TestParseKeys(t *testing.T) {
+	active, verify, err := ParseKeys([]byte(keyFileJSON("b", "a", "b")))
+	assert.NoError(t, err)
+	assert.Equal(t, SigningKey{ID: "b", HashKey: []byte("hash-b"), BlockKey: nil}, active)
+	assert.Equal(t, []SigningKey{{ID: "a", HashKey: []byte("hash-a"), BlockKey: nil}}, verify)
+
+	_, _, err = ParseKeys([]byte(keyFileJSON("c", "a", "b")))
+	assert.ErrorContains(t, err, "active key")
+
+	_, _, err = ParseKeys([]byte(`{"active": "a", "keys": [{"id": "a", "hash_key": "%%"}]}`))
+	assert.ErrorContains(t, err, "decoding hash key")
+
+	_, _, err = ParseKeys([]byte(`{`))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(path, []byte(keyFileJSON("a", "a")), 0o600))
+
+	ring, err := LoadKeyRingFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", ring.ActiveKeyID())
+
+	_, err = LoadKeyRingFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	t.Setenv("REDISSTORE_TEST_KEYS", keyFileJSON("b", "a", "b"))
+	ring, err = LoadKeyRingEnv("REDISSTORE_TEST_KEYS")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", ring.ActiveKeyID())
+
+	_, err = LoadKeyRingEnv("REDISSTORE_TEST_MISSING")
+	assert.Error(t, err)
+}
+
+func TestKeyRingWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(path, []byte(keyFileJSON("a", "a")), 0o600))
+
+	ring, err := LoadKeyRingFile(path)
+	assert.NoError(t, err)
+
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ring.WatchFile(ctx, path, 10*time.Millisecond, func(err error) { errs <- err })
+
+	assert.NoError(t, os.WriteFile(path, []byte(keyFileJSON("b", "a", "b")), 0o600))
+	assert.Eventually(t, func() bool { return ring.ActiveKeyID() == "b" }, time.Second, 10*time.Millisecond)
+
+	// Invalid files keep the current keys.
+	assert.NoError(t, os.WriteFile(path, []byte(`{`), 0o600))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("invalid key file was not reported")
+	}
+	assert.Equal(t, "b", ring.ActiveKeyID())
+}
@@ -0,0 +1,494 @@
+// Package memstore provides an in-memory redisstore.Client.
+//
+// The client is safe for concurrent use and honors expirations. It can be
+// used as a test double, optionally with a fake clock, simulated failures and
+// latency, or as a backend for single instance and development deployments.
+// Backends should use WithJanitor, so that expired keys are removed from
+// memory. Scan matches keys with the glob rules of redis.
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/internal/glob"
+	"github.com/joelrose/redisstore/internal/redisvalue"
+)
+
+// Op identifies a client operation for failure injection.
+type Op string
+
+const (
+	OpGet  Op = "get"
+	OpSet  Op = "set"
+	OpDel  Op = "del"
+	OpScan Op = "scan"
+	OpTTL  Op = "ttl"
+)
+
+type item struct {
+	value []byte
+	// expiresAt is zero for keys without expiration.
+	expiresAt time.Time
+	// seq orders keys by insertion to provide stable scan cursors.
+	seq uint64
+}
+
+// Client is an in-memory implementation of redisstore.Client.
+type Client struct {
+	mu      sync.RWMutex
+	items   map[string]item
+	seq     uint64
+	now     func() time.Time
+	latency time.Duration
+	errs    map[Op]error
+
+	janitorInterval time.Duration
+	stop            chan struct{}
+	stopOnce        sync.Once
+	done            chan struct{}
+}
+
+var (
+	_ redisstore.Client           = (*Client)(nil)
+	_ redisstore.KeyScanner       = (*Client)(nil)
+	_ redisstore.TTLReader        = (*Client)(nil)
+	_ redisstore.BatchClient      = (*Client)(nil)
+	_ redisstore.GetDeleter       = (*Client)(nil)
+	_ redisstore.MultiDeleter     = (*Client)(nil)
+	_ redisstore.Replacer         = (*Client)(nil)
+	_ redisstore.CapabilityProber = (*Client)(nil)
+)
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithClock sets the function used to read the current time, e.g. to move
+// time forward in tests. By default, time.Now is used.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+// WithLatency delays every operation by d.
+func WithLatency(d time.Duration) Option {
+	return func(c *Client) {
+		c.latency = d
+	}
+}
+
+// WithJanitor removes expired keys from memory every interval, see
+// DeleteExpired. Call Close to stop it. By default, expired keys are never
+// returned but stay in memory until DeleteExpired is called, which suits
+// tests but not long running processes.
+func WithJanitor(interval time.Duration) Option {
+	return func(c *Client) {
+		c.janitorInterval = interval
+	}
+}
+
+// New returns an empty Client. If WithJanitor is used, the janitor is started
+// and has to be stopped with Close.
+func New(options ...Option) *Client {
+	c := &Client{
+		mu:              sync.RWMutex{},
+		items:           make(map[string]item),
+		seq:             0,
+		now:             time.Now,
+		latency:         0,
+		errs:            make(map[Op]error),
+		janitorInterval: 0,
+		stop:            make(chan struct{}),
+		stopOnce:        sync.Once{},
+		done:            make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.janitorInterval > 0 {
+		go c.janitor()
+	} else {
+		close(c.done)
+	}
+
+	return c
+}
+
+// Close stops the janitor. The client can still be used afterwards.
+func (c *Client) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	<-c.done
+}
+
+// janitor deletes expired keys until Close is called.
+func (c *Client) janitor() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.DeleteExpired()
+		}
+	}
+}
+
+// SetLatency delays every following operation by d.
+func (c *Client) SetLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.latency = d
+}
+
+// Fail makes every following call of op return err. A nil err clears the
+// failure.
+func (c *Client) Fail(op Op, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		delete(c.errs, op)
+		return
+	}
+
+	c.errs[op] = err
+}
+
+// Get returns the value for a given key or redisstore.ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := c.before(ctx, OpGet); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	it, ok := c.lookup(key)
+	if !ok {
+		return nil, redisstore.ErrNotFound
+	}
+
+	return append([]byte(nil), it.value...), nil
+}
+
+// Set sets the value for a given key. An expiration <= 0 keeps the key
+// forever.
+func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if err := c.before(ctx, OpSet); err != nil {
+		return err
+	}
+
+	b, err := redisvalue.Bytes(value)
+	if err != nil {
+		return fmt.Errorf("memstore: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, b, expiration)
+
+	return nil
+}
+
+// Del deletes a given key.
+func (c *Client) Del(ctx context.Context, key string) error {
+	if err := c.before(ctx, OpDel); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, key)
+
+	return nil
+}
+
+// DelMany deletes keys at once. It fails with the error configured for OpDel.
+func (c *Client) DelMany(ctx context.Context, keys ...string) error {
+	if err := c.before(ctx, OpDel); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+
+	return nil
+}
+
+// GetDel returns the value for a given key and deletes it. It fails with the
+// error configured for OpDel.
+func (c *Client) GetDel(ctx context.Context, key string) ([]byte, error) {
+	if err := c.before(ctx, OpDel); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, ok := c.lookup(key)
+	if !ok {
+		return nil, redisstore.ErrNotFound
+	}
+
+	delete(c.items, key)
+
+	return it.value, nil
+}
+
+// MGet returns the values of keys in the same order, nil for missing keys. It
+// fails with the error configured for OpGet.
+func (c *Client) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := c.before(ctx, OpGet); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		if it, ok := c.lookup(key); ok {
+			values[i] = append([]byte(nil), it.value...)
+		}
+	}
+
+	return values, nil
+}
+
+// SetMany sets all entries at once. It fails with the error configured for
+// OpSet.
+func (c *Client) SetMany(ctx context.Context, entries ...redisstore.BatchEntry) error {
+	if err := c.before(ctx, OpSet); err != nil {
+		return err
+	}
+
+	values := make([][]byte, len(entries))
+	for i, entry := range entries {
+		b, err := redisvalue.Bytes(entry.Value)
+		if err != nil {
+			return fmt.Errorf("memstore: %w", err)
+		}
+		values[i] = b
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, entry := range entries {
+		c.set(entry.Key, values[i], entry.Expiration)
+	}
+
+	return nil
+}
+
+// Replace sets entry and deletes keys at once. It fails with the error
+// configured for OpSet.
+func (c *Client) Replace(ctx context.Context, entry redisstore.BatchEntry, keys ...string) error {
+	if err := c.before(ctx, OpSet); err != nil {
+		return err
+	}
+
+	b, err := redisvalue.Bytes(entry.Value)
+	if err != nil {
+		return fmt.Errorf("memstore: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(entry.Key, b, entry.Expiration)
+	for _, key := range keys {
+		if key != entry.Key {
+			delete(c.items, key)
+		}
+	}
+
+	return nil
+}
+
+// Scan returns up to count keys matching the glob pattern match, which follows
+// the rules of redis. Keys that exist during the whole iteration are returned
+// at least once.
+func (c *Client) Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	if err := c.before(ctx, OpScan); err != nil {
+		return nil, 0, err
+	}
+
+	if count <= 0 {
+		count = 10
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// The cursor is the sequence number of the last returned key. Keys keep
+	// their sequence number when they are overwritten.
+	type entry struct {
+		key string
+		seq uint64
+	}
+
+	entries := make([]entry, 0)
+	for key, it := range c.items {
+		if it.seq > cursor {
+			entries = append(entries, entry{key: key, seq: it.seq})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	var next uint64
+	if int64(len(entries)) > count {
+		entries = entries[:count]
+		next = entries[len(entries)-1].seq
+	}
+
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if _, ok := c.lookup(e.key); !ok {
+			continue
+		}
+
+		if match != "" && !glob.Match(match, e.key) {
+			continue
+		}
+
+		keys = append(keys, e.key)
+	}
+
+	return keys, next, nil
+}
+
+// TTL returns the remaining time to live of a key, -1 for keys without
+// expiration and redisstore.ErrNotFound for missing keys.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if err := c.before(ctx, OpTTL); err != nil {
+		return 0, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	it, ok := c.lookup(key)
+	if !ok {
+		return 0, redisstore.ErrNotFound
+	}
+
+	if it.expiresAt.IsZero() {
+		return -1, nil
+	}
+
+	return it.expiresAt.Sub(c.now()), nil
+}
+
+// Probe reports support for every optional command except keyspace
+// notifications, which the in-memory client does not publish.
+func (c *Client) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	return redisstore.Capabilities{
+		Server:         "memstore",
+		Version:        "",
+		Scan:           true,
+		TTL:            true,
+		GetDel:         true,
+		Config:         false,
+		KeyspaceEvents: false,
+		DelEvents:      false,
+	}, nil
+}
+
+// Len returns the number of keys that did not expire yet.
+func (c *Client) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for key := range c.items {
+		if _, ok := c.lookup(key); ok {
+			n++
+		}
+	}
+
+	return n
+}
+
+// DeleteExpired removes all expired keys. Expired keys are never returned, but
+// only removed from memory by DeleteExpired, e.g. called by the janitor.
+func (c *Client) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.items {
+		if _, ok := c.lookup(key); !ok {
+			delete(c.items, key)
+		}
+	}
+}
+
+// before simulates latency and returns the configured failure for op.
+func (c *Client) before(ctx context.Context, op Op) error {
+	c.mu.RLock()
+	latency, err := c.latency, c.errs[op]
+	c.mu.RUnlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint: wrapcheck
+		case <-timer.C:
+		}
+	}
+
+	return err
+}
+
+// lookup returns the item of a key unless it expired. The caller must hold
+// the lock.
+func (c *Client) lookup(key string) (item, bool) {
+	it, ok := c.items[key]
+	if !ok || (!it.expiresAt.IsZero() && !c.now().Before(it.expiresAt)) {
+		return item{}, false //nolint: exhaustruct
+	}
+
+	return it, true
+}
+
+// set stores a value. The caller must hold the write lock.
+func (c *Client) set(key string, value []byte, expiration time.Duration) {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = c.now().Add(expiration)
+	}
+
+	it, ok := c.lookup(key)
+	if !ok {
+		c.seq++
+		it.seq = c.seq
+	}
+
+	c.items[key] = item{
+		value:     value,
+		expiresAt: expiresAt,
+		seq:       it.seq,
+	}
+}
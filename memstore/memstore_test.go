@@ -0,0 +1,305 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("getdel", func(t *testing.T) {
+		c := New()
+
+		_, err := c.GetDel(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+		val, err := c.GetDel(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("get set del", func(t *testing.T) {
+		c := New()
+
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), 0))
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+
+		assert.NoError(t, c.Set(ctx, "string", "value", 0))
+		assert.NoError(t, c.Set(ctx, "int", 42, 0))
+		val, err = c.Get(ctx, "int")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("42"), val)
+
+		assert.Error(t, c.Set(ctx, "struct", struct{}{}, 0))
+
+		assert.NoError(t, c.Del(ctx, "key"))
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+	})
+
+	t.Run("del many", func(t *testing.T) {
+		c := New()
+
+		assert.NoError(t, c.Set(ctx, "a", []byte("value"), 0))
+		assert.NoError(t, c.Set(ctx, "b", []byte("value"), 0))
+		assert.NoError(t, c.Set(ctx, "c", []byte("value"), 0))
+		assert.NoError(t, c.DelMany(ctx, "a", "b", "missing"))
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		c := New()
+
+		assert.NoError(t, c.Set(ctx, "old", []byte("value"), 0))
+		assert.NoError(t, c.Replace(ctx, redisstore.BatchEntry{Key: "new", Value: []byte("merged"), Expiration: 0}, "old", "missing"))
+
+		_, err := c.Get(ctx, "old")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		val, err := c.Get(ctx, "new")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("merged"), val)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		c := New()
+
+		assert.NoError(t, c.SetMany(ctx,
+			redisstore.BatchEntry{Key: "a", Value: "1", Expiration: 0},
+			redisstore.BatchEntry{Key: "b", Value: []byte("2"), Expiration: time.Minute},
+		))
+
+		values, err := c.MGet(ctx, "a", "missing", "b")
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("1"), nil, []byte("2")}, values)
+
+		assert.Error(t, c.SetMany(ctx, redisstore.BatchEntry{Key: "c", Value: struct{}{}, Expiration: 0}))
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("values are copied", func(t *testing.T) {
+		c := New()
+
+		b := []byte("value")
+		assert.NoError(t, c.Set(ctx, "key", b, 0))
+		b[0] = 'X'
+
+		val, _ := c.Get(ctx, "key")
+		val[1] = 'X'
+
+		val, _ = c.Get(ctx, "key")
+		assert.Equal(t, []byte("value"), val)
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := New(WithClock(clock.Now))
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		assert.NoError(t, c.Set(ctx, "forever", "value", 0))
+
+		ttl, err := c.TTL(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl)
+
+		ttl, err = c.TTL(ctx, "forever")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(-1), ttl)
+
+		clock.Advance(59 * time.Second)
+		_, err = c.Get(ctx, "key")
+		assert.NoError(t, err)
+
+		clock.Advance(time.Second)
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		_, err = c.TTL(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		assert.Equal(t, 1, c.Len())
+
+		c.DeleteExpired()
+		assert.Len(t, c.items, 1)
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		c := New()
+		for _, key := range []string{"a1", "a2", "b1", "a3", "a4"} {
+			assert.NoError(t, c.Set(ctx, key, "value", 0))
+		}
+
+		var (
+			cursor uint64
+			keys   []string
+			pages  int
+		)
+		for first := true; first || cursor != 0; first = false {
+			page, next, err := c.Scan(ctx, cursor, "a*", 2)
+			assert.NoError(t, err)
+
+			// Deleting keys while scanning must not skip other keys.
+			for _, key := range page {
+				assert.NoError(t, c.Del(ctx, key))
+			}
+
+			keys = append(keys, page...)
+			cursor = next
+			pages++
+		}
+
+		sort.Strings(keys)
+		assert.Equal(t, []string{"a1", "a2", "a3", "a4"}, keys)
+		assert.Equal(t, 3, pages)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("scan glob", func(t *testing.T) {
+		c := New()
+		for _, key := range []string{"session_a/b", "session_*", "session_x", "cache_a"} {
+			assert.NoError(t, c.Set(ctx, key, "value", 0))
+		}
+
+		scan := func(match string) []string {
+			keys, _, err := c.Scan(ctx, 0, match, 10)
+			assert.NoError(t, err)
+			sort.Strings(keys)
+
+			return keys
+		}
+
+		assert.Equal(t, []string{"session_*", "session_a/b", "session_x"}, scan("session_*"))
+		assert.Equal(t, []string{"session_*"}, scan("session_\\*"))
+		assert.Equal(t, []string{"session_a/b"}, scan("session_?/?"))
+		assert.Equal(t, []string{"session_x"}, scan("session_[^*a]"))
+	})
+
+	t.Run("janitor", func(t *testing.T) {
+		clock := &fakeClock{now: time.Now()}
+		c := New(WithClock(clock.Now), WithJanitor(time.Millisecond))
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		clock.Advance(time.Minute)
+
+		assert.Eventually(t, func() bool {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+
+			return len(c.items) == 0
+		}, time.Second, time.Millisecond)
+
+		c.Close()
+		New().Close()
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		c := New()
+		errDown := errors.New("down")
+
+		c.Fail(OpSet, errDown)
+		assert.ErrorIs(t, c.Set(ctx, "key", "value", 0), errDown)
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		c.Fail(OpSet, nil)
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		c := New(WithLatency(20 * time.Millisecond))
+
+		start := time.Now()
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		c.SetLatency(time.Hour)
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err := c.Get(timeout, "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestProbe(t *testing.T) {
+	store := redisstore.New(New(), nil)
+
+	caps, err := store.Probe(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "memstore", caps.Server)
+	assert.True(t, caps.Scan)
+	assert.False(t, caps.KeyspaceEvents)
+	assert.Equal(t, caps, store.Capabilities())
+}
+
+func TestStore(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	client := New(WithClock(clock.Now))
+	store := redisstore.New(client, [][]byte{[]byte("secret")})
+	store.Options.MaxAge = 60
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "session")
+	assert.NoError(t, err)
+	session.Values["key"] = "value"
+	assert.NoError(t, session.Save(req, res))
+
+	load := func() (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+
+		val, _ := session.Values["key"].(string)
+
+		return val, session.IsNew
+	}
+
+	val, isNew := load()
+	assert.False(t, isNew)
+	assert.Equal(t, "value", val)
+
+	clock.Advance(time.Minute)
+
+	_, isNew = load()
+	assert.True(t, isNew)
+}
@@ -0,0 +1,225 @@
+package redisstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+type middlewareKey struct{}
+
+// Middleware returns a middleware that loads the named sessions of every
+// request, see FromContext. Sessions whose values or max age changed are
+// saved right before the response headers are written, or when the handler
+// returns without writing a response. New sessions without values are not
+// saved.
+//
+// The middleware has the signature of net/http and chi style routers. Its
+// response writer supports http.Flusher and http.Hijacker, so sessions are
+// saved before a streamed response is flushed or a connection is hijacked.
+// If saving fails, the response is replaced by 500 Internal Server Error. A
+// name that is not a valid cookie name fails every request the same way.
+func Middleware(store *Store, names ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Sessions that fail to load are replaced by new ones. Only an
+			// invalid cookie name yields no session, which fails the request.
+			loaded, _ := store.LoadMany(r, names...)
+			for _, name := range names {
+				if loaded[name] == nil {
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+					return
+				}
+			}
+
+			state := &middlewareState{
+				store:   store,
+				tracked: make([]trackedSession, 0, len(names)),
+				byName:  make(map[string]*sessions.Session, len(names)),
+			}
+
+			// Keep the sessions of outer middlewares accessible.
+			if parent, ok := r.Context().Value(middlewareKey{}).(*middlewareState); ok {
+				for name, session := range parent.byName {
+					state.byName[name] = session
+				}
+			}
+
+			for _, name := range names {
+				session := loaded[name]
+				state.byName[name] = session
+				state.tracked = append(state.tracked, store.track(session))
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), middlewareKey{}, state))
+
+			sw := &sessionWriter{
+				ResponseWriter: w,
+				request:        r,
+				state:          state,
+				committed:      false,
+				failed:         false,
+			}
+
+			next.ServeHTTP(sw, r)
+			sw.commit()
+		})
+	}
+}
+
+// FromContext returns the named session loaded by Middleware or nil if the
+// middleware did not load it.
+func FromContext(ctx context.Context, name string) *sessions.Session {
+	state, ok := ctx.Value(middlewareKey{}).(*middlewareState)
+	if !ok {
+		return nil
+	}
+
+	return state.byName[name]
+}
+
+type middlewareState struct {
+	store   *Store
+	tracked []trackedSession
+	byName  map[string]*sessions.Session
+}
+
+// trackedSession is a session with the state it was loaded with.
+type trackedSession struct {
+	session *sessions.Session
+	values  map[interface{}][]byte
+	maxAge  int
+}
+
+// track records the state of a loaded session.
+func (s *Store) track(session *sessions.Session) trackedSession {
+	values := make(map[interface{}][]byte, len(session.Values))
+	for k, v := range session.Values {
+		// Values that can not be serialized are always considered changed.
+		if b, err := encodeValue(s.serializer, k, v); err == nil {
+			values[k] = b
+		}
+	}
+
+	return trackedSession{
+		session: session,
+		values:  values,
+		maxAge:  session.Options.MaxAge,
+	}
+}
+
+// dirty reports whether a session changed since it was loaded.
+func (t trackedSession) dirty(serializer SessionSerializer) bool {
+	if t.session.Options.MaxAge != t.maxAge {
+		// Deleting a session that was never saved is a no-op.
+		return t.session.ID != "" || t.session.Options.MaxAge > 0
+	}
+
+	if len(t.values) != len(t.session.Values) {
+		return true
+	}
+
+	for k, v := range t.session.Values {
+		loaded, ok := t.values[k]
+		if !ok {
+			return true
+		}
+
+		b, err := encodeValue(serializer, k, v)
+		if err != nil || !bytes.Equal(b, loaded) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sessionWriter saves the dirty sessions of a request before the response
+// headers are written.
+type sessionWriter struct {
+	http.ResponseWriter
+	request *http.Request
+	state   *middlewareState
+
+	committed bool
+	failed    bool
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.commit()
+	if w.failed {
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commit()
+	if w.failed {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b) //nolint: wrapcheck
+}
+
+// Flush implements http.Flusher.
+func (w *sessionWriter) Flush() {
+	w.commit()
+	if w.failed {
+		return
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker.
+func (w *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.commit()
+
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("redisstore(middleware): response writer does not support hijacking")
+	}
+
+	return h.Hijack() //nolint: wrapcheck
+}
+
+// Unwrap returns the wrapped response writer for http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// commit saves the dirty sessions once.
+func (w *sessionWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	store := w.state.store
+
+	dirty := make([]*sessions.Session, 0, len(w.state.tracked))
+	for _, t := range w.state.tracked {
+		if t.dirty(store.serializer) {
+			dirty = append(dirty, t.session)
+		}
+	}
+
+	if len(dirty) == 0 {
+		return
+	}
+
+	if err := store.SaveMany(w.request, w.ResponseWriter, dirty...); err != nil {
+		w.failed = true
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
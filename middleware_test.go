@@ -0,0 +1,147 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingClient is a scanClient whose writes fail.
+type failingClient struct {
+	scanClient
+}
+
+func (c *failingClient) Set(context.Context, string, interface{}, time.Duration) error {
+	return errors.New("unavailable")
+}
+
+func TestMiddleware(t *testing.T) {
+	client := &batchClient{scanClient: scanClient{data: map[string][]byte{}}}
+	store := New(client, [][]byte{[]byte("key")})
+
+	var handler http.HandlerFunc
+	h := Middleware(store, "auth", "cart")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+	}))
+
+	serve := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		return res
+	}
+
+	// New sessions without values are not saved.
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		assert.NotNil(t, FromContext(r.Context(), "auth"))
+		assert.Nil(t, FromContext(r.Context(), "other"))
+	}
+	res := serve(nil)
+	assert.Empty(t, res.Result().Cookies())
+	assert.Empty(t, client.data)
+
+	// Changed sessions are saved before the headers are written.
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), "auth").Values["user"] = "a"
+		FromContext(r.Context(), "auth").Values["roles"] = []string{"admin"}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("ok"))
+	}
+	res = serve(nil)
+	assert.Equal(t, http.StatusCreated, res.Code)
+	cookies := res.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Len(t, client.data, 1)
+	assert.Equal(t, 1, client.setManys)
+
+	// Unchanged sessions are loaded in one round trip and not saved again.
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		session := FromContext(r.Context(), "auth")
+		assert.False(t, session.IsNew)
+		assert.Equal(t, "a", session.Values["user"])
+	}
+	client.gets, client.mgets = 0, 0
+	res = serve(cookies)
+	assert.Empty(t, res.Result().Cookies())
+	assert.Equal(t, 1, client.mgets)
+	assert.Equal(t, 0, client.gets)
+	assert.Equal(t, 1, client.setManys)
+
+	// Streamed responses save sessions before the first flush.
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), "cart").Values["items"] = "1"
+		w.(http.Flusher).Flush()
+		assert.NoError(t, http.NewResponseController(w).Flush())
+	}
+	res = serve(cookies)
+	assert.True(t, res.Flushed)
+	assert.Len(t, res.Result().Cookies(), 1)
+	assert.Len(t, client.data, 2)
+
+	// Deleting a session is saved too.
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), "auth").Options.MaxAge = -1
+	}
+	res = serve(cookies)
+	assert.Len(t, res.Result().Cookies(), 1)
+	assert.Len(t, client.data, 1)
+}
+
+func TestMiddlewareNested(t *testing.T) {
+	store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+	other := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("other")})
+
+	var called bool
+	h := Middleware(store, "auth")(Middleware(other, "prefs")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		assert.NotNil(t, FromContext(r.Context(), "auth"))
+		assert.NotNil(t, FromContext(r.Context(), "prefs"))
+	})))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, called)
+}
+
+func TestMiddlewareSaveError(t *testing.T) {
+	store := New(&failingClient{scanClient{data: map[string][]byte{}}}, [][]byte{[]byte("key")})
+
+	h := Middleware(store, "auth")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context(), "auth").Values["user"] = "a"
+		_, _ = w.Write([]byte("logged in"))
+	}))
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.NotContains(t, res.Body.String(), "logged in")
+}
+
+func TestMiddlewareInvalidName(t *testing.T) {
+	store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+
+	var called bool
+	h := Middleware(store, "auth", "in valid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	res := httptest.NewRecorder()
+	assert.NotPanics(t, func() {
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.False(t, called)
+}
+
+func TestFromContextWithoutMiddleware(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background(), "auth"))
+}
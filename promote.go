@@ -0,0 +1,168 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// Replacer is an optional Client capability to write a key and delete other
+// keys in a single atomic operation, e.g. with MULTI/EXEC.
+type Replacer interface {
+	// Replace sets entry and deletes keys atomically. Missing keys are
+	// ignored.
+	Replace(ctx context.Context, entry BatchEntry, keys ...string) error
+}
+
+// MergeFunc combines the values of an anonymous session with the values of
+// the authenticated session it is promoted to. It returns the values of the
+// promoted session and may modify and return either map.
+type MergeFunc func(anonymous, authenticated map[interface{}]interface{}) map[interface{}]interface{}
+
+// PreferAuthenticated is a MergeFunc that keeps all values of the anonymous
+// session that are not set in the authenticated session.
+func PreferAuthenticated(anonymous, authenticated map[interface{}]interface{}) map[interface{}]interface{} {
+	for k, v := range anonymous {
+		if _, ok := authenticated[k]; !ok {
+			authenticated[k] = v
+		}
+	}
+
+	return authenticated
+}
+
+// PreferAnonymous is a MergeFunc that overwrites the values of the
+// authenticated session with the values of the anonymous session.
+func PreferAnonymous(anonymous, authenticated map[interface{}]interface{}) map[interface{}]interface{} {
+	for k, v := range anonymous {
+		authenticated[k] = v
+	}
+
+	return authenticated
+}
+
+// Promote merges an anonymous session into an authenticated session after a
+// login, e.g. to keep the shopping cart of a visitor. The values of both
+// sessions are combined with merge, PreferAuthenticated if nil, and stored
+// under a new ID of the authenticated session to prevent session fixation.
+// The anonymous session and the previous ID of the authenticated session are
+// deleted with their auxiliary keys.
+//
+// The write and the deletes are a single atomic operation if the client
+// implements Replacer. Otherwise, the promoted session is written before the
+// old ones are deleted, so a failure never loses the merged values.
+//
+// authenticated may be a new session, e.g. from Store.New, or an existing
+// session of the user. The anonymous session is marked as deleted and its
+// cookie is cleared if it has a different name. It must not be saved
+// afterwards. Flash messages and authentication events of both sessions are
+// not moved. Impersonated sessions are not promoted and ErrImpersonating is
+// returned.
+func (s *Store) Promote(
+	r *http.Request,
+	w http.ResponseWriter,
+	anonymous, authenticated *sessions.Session,
+	merge MergeFunc,
+) error {
+	if authenticated.Options.MaxAge <= 0 {
+		return errors.New("redisstore(promote): authenticated session is deleted")
+	}
+	if _, ok := Impersonating(anonymous); ok {
+		return ErrImpersonating
+	}
+	if _, ok := Impersonating(authenticated); ok {
+		return ErrImpersonating
+	}
+
+	if merge == nil {
+		merge = PreferAuthenticated
+	}
+
+	var ids []string
+	if anonymous.ID != "" {
+		ids = append(ids, anonymous.ID)
+	}
+	if authenticated.ID != "" && authenticated.ID != anonymous.ID {
+		ids = append(ids, authenticated.ID)
+	}
+
+	var keys []string
+	for _, id := range ids {
+		sessionKeys, err := s.sessionKeys(r.Context(), id)
+		if err != nil {
+			return fmt.Errorf("redisstore(promote): %v", err)
+		}
+		keys = append(keys, sessionKeys...)
+	}
+
+	replaced := *authenticated
+	replaced.Values = merge(anonymous.Values, authenticated.Values)
+	replaced.ID = s.keyGen()
+	replaced.IsNew = true
+	if replaced.Values == nil {
+		replaced.Values = make(map[interface{}]interface{})
+	}
+
+	s.bind(r, &replaced)
+
+	b, err := s.serializer.Serialize(&replaced)
+	if err != nil {
+		return fmt.Errorf("redisstore(promote): serializing session: %v", err)
+	}
+
+	entry := BatchEntry{
+		Key:        s.SessionKey(replaced.ID),
+		Value:      b,
+		Expiration: time.Duration(replaced.Options.MaxAge) * time.Second,
+	}
+	if err := s.replace(r.Context(), entry, keys); err != nil {
+		return fmt.Errorf("redisstore(promote): %v", err)
+	}
+
+	if anonymous.ID != "" {
+		s.emit(SessionDeleted, r, anonymous, nil, nil)
+	}
+	if authenticated.ID != "" && authenticated.ID != anonymous.ID {
+		s.emit(SessionDeleted, r, authenticated, nil, nil)
+	}
+
+	if anonymous.Name() != authenticated.Name() && anonymous.ID != "" {
+		anonymous.Options.MaxAge = -1
+		s.writeID(r, w, anonymous, "")
+	}
+	anonymous.ID = ""
+	anonymous.Options.MaxAge = -1
+
+	authenticated.ID = replaced.ID
+	authenticated.Values = replaced.Values
+	authenticated.IsNew = false
+
+	return s.saved(r, w, authenticated, nil)
+}
+
+// replace writes entry and deletes keys, atomically if the client supports it.
+func (s *Store) replace(ctx context.Context, entry BatchEntry, keys []string) error {
+	if replacer, ok := s.client.(Replacer); ok {
+		if err := replacer.Replace(ctx, entry, keys...); err != nil {
+			return fmt.Errorf("replacing sessions: %v", err)
+		}
+
+		return nil
+	}
+
+	if err := s.client.Set(ctx, entry.Key, entry.Value, entry.Expiration); err != nil {
+		return fmt.Errorf("setting session: %v", err)
+	}
+
+	for _, key := range keys {
+		if err := s.client.Del(ctx, key); err != nil {
+			return fmt.Errorf("deleting session: %v", err)
+		}
+	}
+
+	return nil
+}
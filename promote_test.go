@@ -0,0 +1,128 @@
+package redisstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replaceClient is a scanClient implementing Replacer that counts its calls.
+type replaceClient struct {
+	scanClient
+	replaces int
+}
+
+func (c *replaceClient) Replace(_ context.Context, entry BatchEntry, keys ...string) error {
+	c.replaces++
+
+	for _, key := range keys {
+		delete(c.data, key)
+	}
+	c.data[entry.Key] = entry.Value.([]byte)
+
+	return nil
+}
+
+func TestStorePromote(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   func(data map[string][]byte) (Client, *int)
+		replaces int
+	}{
+		{"replacer", func(data map[string][]byte) (Client, *int) {
+			c := &replaceClient{scanClient: scanClient{data: data}}
+			return c, &c.replaces
+		}, 1},
+		{"fallback", func(data map[string][]byte) (Client, *int) {
+			return &scanClient{data: data}, new(int)
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string][]byte{}
+			client, replaces := tt.client(data)
+			rec := &recorder{}
+			store := New(client, [][]byte{[]byte("key")}, WithSerializer(JSONSerializer{}), WithEventHandler(rec))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+
+			anonymous, _ := store.New(req, "cart")
+			anonymous.Values["items"] = "book"
+			anonymous.Values["user"] = "anonymous"
+			assert.NoError(t, store.Save(req, res, anonymous))
+
+			authenticated, _ := store.New(req, "auth")
+			authenticated.Values["user"] = "alice"
+			assert.NoError(t, store.Save(req, res, authenticated))
+			assert.NoError(t, store.AddFlash(req, res, anonymous, "info", "added"))
+
+			anonymousID, authenticatedID := anonymous.ID, authenticated.ID
+			rec.events = nil
+
+			res = httptest.NewRecorder()
+			assert.NoError(t, store.Promote(req, res, anonymous, authenticated, nil))
+
+			assert.Equal(t, tt.replaces, *replaces)
+			assert.NotEqual(t, authenticatedID, authenticated.ID)
+			assert.Equal(t, "alice", authenticated.Values["user"])
+			assert.Equal(t, "book", authenticated.Values["items"])
+			assert.Empty(t, anonymous.ID)
+			assert.Equal(t, []EventType{SessionDeleted, SessionDeleted, SessionCreated}, rec.types())
+
+			assert.NotContains(t, data, store.SessionKey(anonymousID))
+			assert.NotContains(t, data, store.SessionKey(authenticatedID))
+			assert.Contains(t, data, store.SessionKey(authenticated.ID))
+			assert.Len(t, data, 1)
+
+			cookies := res.Result().Cookies()
+			assert.Len(t, cookies, 2)
+			assert.Equal(t, "cart", cookies[0].Name)
+			assert.Equal(t, -1, cookies[0].MaxAge)
+			assert.Equal(t, "auth", cookies[1].Name)
+
+			// The promoted session is loaded with the new cookie.
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookies[1])
+			loaded, err := store.New(req, "auth")
+			assert.NoError(t, err)
+			assert.False(t, loaded.IsNew)
+			assert.Equal(t, "book", loaded.Values["items"])
+		})
+	}
+
+	t.Run("prefer anonymous", func(t *testing.T) {
+		store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+
+		anonymous, _ := store.New(req, "session")
+		anonymous.Values["theme"] = "dark"
+		assert.NoError(t, store.Save(req, res, anonymous))
+
+		// A new session with the same name replaces the anonymous cookie.
+		authenticated, _ := store.New(req, "session")
+		authenticated.Values["theme"] = "light"
+		authenticated.Values["user"] = "alice"
+
+		res = httptest.NewRecorder()
+		assert.NoError(t, store.Promote(req, res, anonymous, authenticated, PreferAnonymous))
+		assert.Equal(t, "dark", authenticated.Values["theme"])
+		assert.Equal(t, "alice", authenticated.Values["user"])
+		assert.Len(t, res.Result().Cookies(), 1)
+	})
+
+	t.Run("deleted session", func(t *testing.T) {
+		store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		anonymous, _ := store.New(req, "cart")
+		authenticated, _ := store.New(req, "auth")
+		authenticated.Options.MaxAge = -1
+
+		assert.Error(t, store.Promote(req, httptest.NewRecorder(), anonymous, authenticated, nil))
+	})
+}
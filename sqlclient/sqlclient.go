@@ -0,0 +1,289 @@
+// Package sqlclient provides a redisstore.Client backed by a database/sql
+// database, for deployments without redis.
+//
+// Values are stored in a table with the columns key, value and expires_at.
+// Expired rows are never returned and removed by a background sweeper. The
+// SQL is written for PostgreSQL and SQLite.
+package sqlclient
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	"github.com/joelrose/redisstore/internal/redisvalue"
+)
+
+// Dialect selects the SQL dialect of the database.
+type Dialect string
+
+const (
+	PostgreSQL Dialect = "postgres"
+	SQLite     Dialect = "sqlite"
+)
+
+// Client is an implementation of redisstore.Client on top of database/sql.
+type Client struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+	now     func() time.Time
+
+	sweepInterval time.Duration
+	onError       func(err error)
+	stop          chan struct{}
+	stopOnce      sync.Once
+	done          chan struct{}
+}
+
+var (
+	_ redisstore.Client           = (*Client)(nil)
+	_ redisstore.TTLReader        = (*Client)(nil)
+	_ redisstore.CapabilityProber = (*Client)(nil)
+)
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithTable sets the name of the table. It is used in queries as is and must
+// not be user input. By default, the table "sessions" is used.
+func WithTable(table string) Option {
+	return func(c *Client) {
+		c.table = table
+	}
+}
+
+// WithSweepInterval sets how often expired rows are deleted. An interval <= 0
+// disables the sweeper. By default, expired rows are deleted every minute.
+func WithSweepInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.sweepInterval = interval
+	}
+}
+
+// WithSweepErrorHandler sets a function that is called with every error of
+// the sweeper.
+func WithSweepErrorHandler(fn func(err error)) Option {
+	return func(c *Client) {
+		c.onError = fn
+	}
+}
+
+// WithClock sets the function used to read the current time. By default,
+// time.Now is used.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+const (
+	defaultTable         = "sessions"
+	defaultSweepInterval = time.Minute
+)
+
+// New returns a Client and starts the sweeper. Call Close to stop it. The
+// table is not created, see CreateTable.
+func New(db *sql.DB, dialect Dialect, options ...Option) *Client {
+	c := &Client{
+		db:            db,
+		dialect:       dialect,
+		table:         defaultTable,
+		now:           time.Now,
+		sweepInterval: defaultSweepInterval,
+		onError:       func(error) {},
+		stop:          make(chan struct{}),
+		stopOnce:      sync.Once{},
+		done:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.sweepInterval > 0 {
+		go c.sweep()
+	} else {
+		close(c.done)
+	}
+
+	return c
+}
+
+// CreateTable creates the table and its index if they do not exist.
+func (c *Client) CreateTable(ctx context.Context) error {
+	blob := "BYTEA"
+	if c.dialect == SQLite {
+		blob = "BLOB"
+	}
+
+	stmts := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			key TEXT PRIMARY KEY,
+			value %s NOT NULL,
+			expires_at BIGINT
+		)`, c.table, blob),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)`, c.table, c.table),
+	}
+
+	for _, stmt := range stmts {
+		if _, err := c.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("sqlclient: creating table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value for a given key or redisstore.ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	query := fmt.Sprintf(
+		`SELECT value FROM %s WHERE key = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		c.table,
+	)
+
+	var val []byte
+	err := c.db.QueryRowContext(ctx, query, key, c.millis()).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, redisstore.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sqlclient: getting value: %w", err)
+	}
+
+	return val, nil
+}
+
+// Set inserts or replaces the value for a given key. An expiration <= 0 keeps
+// the key forever.
+func (c *Client) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	b, err := redisvalue.Bytes(value)
+	if err != nil {
+		return fmt.Errorf("sqlclient: %w", err)
+	}
+
+	var expiresAt sql.NullInt64
+	if expiration > 0 {
+		expiresAt = sql.NullInt64{Int64: c.now().Add(expiration).UnixMilli(), Valid: true}
+	}
+
+	query := fmt.Sprintf(
+		`INSERT INTO %s (key, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		c.table,
+	)
+
+	if _, err := c.db.ExecContext(ctx, query, key, b, expiresAt); err != nil {
+		return fmt.Errorf("sqlclient: setting value: %w", err)
+	}
+
+	return nil
+}
+
+// Del deletes a given key.
+func (c *Client) Del(ctx context.Context, key string) error {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, c.table)
+
+	if _, err := c.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("sqlclient: deleting value: %w", err)
+	}
+
+	return nil
+}
+
+// TTL returns the remaining time to live of a key, -1 for keys without
+// expiration and redisstore.ErrNotFound for missing keys.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	query := fmt.Sprintf(
+		`SELECT expires_at FROM %s WHERE key = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		c.table,
+	)
+
+	var expiresAt sql.NullInt64
+	err := c.db.QueryRowContext(ctx, query, key, c.millis()).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, redisstore.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sqlclient: getting ttl: %w", err)
+	}
+
+	if !expiresAt.Valid {
+		return -1, nil
+	}
+
+	return time.UnixMilli(expiresAt.Int64).Sub(c.now()), nil
+}
+
+// Probe checks the database connection. The table can not be scanned by key
+// pattern and rows can not be read and deleted at once, so the store uses
+// GET and DEL instead of GETDEL and Admin is not available.
+func (c *Client) Probe(ctx context.Context) (redisstore.Capabilities, error) {
+	if err := c.db.PingContext(ctx); err != nil {
+		return redisstore.Capabilities{}, fmt.Errorf("sqlclient: %w", err) //nolint: exhaustruct
+	}
+
+	return redisstore.Capabilities{
+		Server:         string(c.dialect),
+		Version:        "",
+		Scan:           false,
+		TTL:            true,
+		GetDel:         false,
+		Config:         false,
+		KeyspaceEvents: false,
+		DelEvents:      false,
+	}, nil
+}
+
+// DeleteExpired deletes all expired rows and returns their number.
+func (c *Client) DeleteExpired(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= $1`, c.table)
+
+	res, err := c.db.ExecContext(ctx, query, c.millis())
+	if err != nil {
+		return 0, fmt.Errorf("sqlclient: deleting expired values: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("sqlclient: deleting expired values: %w", err)
+	}
+
+	return n, nil
+}
+
+// Close stops the sweeper. It does not close the database.
+func (c *Client) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	<-c.done
+}
+
+// sweep deletes expired rows until Close is called.
+func (c *Client) sweep() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if _, err := c.DeleteExpired(context.Background()); err != nil {
+				c.onError(err)
+			}
+		}
+	}
+}
+
+// millis returns the current time in unix milliseconds, the unit of
+// expires_at.
+func (c *Client) millis() int64 {
+	return c.now().UnixMilli()
+}
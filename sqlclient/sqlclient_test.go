@@ -0,0 +1,156 @@
+package sqlclient
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelrose/redisstore"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newClient(t *testing.T, options ...Option) *Client {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal("failed to open database: ", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	c := New(db, SQLite, options...)
+	t.Cleanup(c.Close)
+
+	if err := c.CreateTable(context.Background()); err != nil {
+		t.Fatal("failed to create table: ", err)
+	}
+
+	return c
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("get set del", func(t *testing.T) {
+		c := newClient(t)
+
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("v1"), 0))
+		assert.NoError(t, c.Set(ctx, "key", []byte("v2"), 0))
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("v2"), val)
+
+		assert.NoError(t, c.Set(ctx, "int", 42, 0))
+		val, err = c.Get(ctx, "int")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("42"), val)
+
+		assert.NoError(t, c.Del(ctx, "key"))
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+	})
+
+	t.Run("expiration", func(t *testing.T) {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		c := newClient(t, WithClock(clock.Now), WithSweepInterval(0))
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		assert.NoError(t, c.Set(ctx, "forever", "value", 0))
+
+		ttl, err := c.TTL(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl)
+
+		ttl, err = c.TTL(ctx, "forever")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(-1), ttl)
+
+		clock.Advance(time.Minute)
+		_, err = c.Get(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+		_, err = c.TTL(ctx, "key")
+		assert.ErrorIs(t, err, redisstore.ErrNotFound)
+
+		// Set replaces the expiration of an expired row.
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Minute))
+		_, err = c.Get(ctx, "key")
+		assert.NoError(t, err)
+
+		clock.Advance(time.Minute)
+		n, err := c.DeleteExpired(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+
+	t.Run("sweeper", func(t *testing.T) {
+		c := newClient(t, WithSweepInterval(10*time.Millisecond))
+
+		assert.NoError(t, c.Set(ctx, "key", "value", time.Millisecond))
+		assert.Eventually(t, func() bool {
+			var n int
+			err := c.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&n)
+			return err == nil && n == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("table", func(t *testing.T) {
+		c := newClient(t, WithTable("custom_sessions"))
+
+		assert.NoError(t, c.Set(ctx, "key", "value", 0))
+		_, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+	})
+}
+
+func TestStore(t *testing.T) {
+	store := redisstore.New(newClient(t), [][]byte{[]byte("secret")})
+
+	caps, err := store.Probe(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", caps.Server)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := store.New(req, "session")
+	assert.NoError(t, err)
+	session.Values["key"] = "value"
+	assert.NoError(t, session.Save(req, res))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", strings.Join(res.Header().Values("Set-Cookie"), "; "))
+
+	session, err = store.New(req, "session")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, "value", session.Values["key"])
+}
@@ -0,0 +1,189 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// authKeyName is the name of the auxiliary keys that store authentication
+// events.
+const authKeyName = "auth"
+
+// maxAuthEvents is the number of authentication events kept per session.
+const maxAuthEvents = 16
+
+// AuthEvent is an authentication of the user of a session, e.g. a login or a
+// second factor.
+type AuthEvent struct {
+	// Time is set by the store when the event is recorded.
+	Time time.Time `json:"time"`
+	// Method describes how the user authenticated, e.g. "password" or "webauthn".
+	Method string `json:"method"`
+	// Level is the assurance level of the method. Higher levels satisfy
+	// requirements of lower levels.
+	Level int `json:"level"`
+}
+
+// RecordAuth records that the user of a session authenticated with method at
+// an assurance level. The event is timestamped by the server and stored next
+// to the session instead of in its values, so handlers can not forge it by
+// writing values. No Save is needed; sessions without an ID are saved first.
+// Events expire with the session.
+//
+// Events are bound to the session ID and are deleted with the session, also by
+// Regenerate. Record them after Regenerate, e.g. at the end of a login.
+// Promote and Impersonate start without events.
+func (s *Store) RecordAuth(r *http.Request, w http.ResponseWriter, session *sessions.Session, method string, level int) error {
+	if session.Options.MaxAge <= 0 {
+		return errors.New("redisstore(auth): session is deleted")
+	}
+
+	if session.ID == "" {
+		if err := s.Save(r, w, session); err != nil {
+			return err
+		}
+	}
+
+	events, err := s.AuthEvents(r, session)
+	if err != nil {
+		return err
+	}
+
+	events = append(events, AuthEvent{Time: time.Now(), Method: method, Level: level})
+	if len(events) > maxAuthEvents {
+		events = events[len(events)-maxAuthEvents:]
+	}
+
+	b, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("redisstore(auth): encoding events: %v", err)
+	}
+
+	maxAge := time.Duration(session.Options.MaxAge) * time.Second
+	if err := s.setAux(r.Context(), session.ID, authKeyName, b, maxAge); err != nil {
+		return fmt.Errorf("redisstore(auth): setting events: %v", err)
+	}
+
+	return nil
+}
+
+// AuthEvents returns the recorded authentication events of a session, the
+// oldest first.
+func (s *Store) AuthEvents(r *http.Request, session *sessions.Session) ([]AuthEvent, error) {
+	if session.ID == "" {
+		return nil, nil
+	}
+
+	val, err := s.client.Get(r.Context(), s.auxKey(session.ID, authKeyName))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redisstore(auth): getting events: %v", err)
+	}
+
+	var events []AuthEvent
+	if err := json.Unmarshal(val, &events); err != nil {
+		return nil, fmt.Errorf("redisstore(auth): decoding events: %v", err)
+	}
+
+	return events, nil
+}
+
+// FreshAuth reports whether the user of a session authenticated with at least
+// level within maxAge. Events with a time in the future are ignored.
+func (s *Store) FreshAuth(r *http.Request, session *sessions.Session, maxAge time.Duration, level int) (bool, error) {
+	events, err := s.AuthEvents(r, session)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	for _, event := range events {
+		if event.Level >= level && !event.Time.After(now) && now.Sub(event.Time) <= maxAge {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// FreshAuthOption configures RequireFreshAuth.
+type FreshAuthOption func(f *freshAuth)
+
+// WithStepUpRedirect redirects requests without a fresh authentication to
+// url, e.g. a login form, instead of answering with 401 Unauthorized.
+func WithStepUpRedirect(url string) FreshAuthOption {
+	return func(f *freshAuth) {
+		f.handler = http.RedirectHandler(url, http.StatusSeeOther)
+	}
+}
+
+// WithStepUpHandler sets the handler of requests without a fresh
+// authentication.
+func WithStepUpHandler(handler http.Handler) FreshAuthOption {
+	return func(f *freshAuth) {
+		f.handler = handler
+	}
+}
+
+type freshAuth struct {
+	store   *Store
+	name    string
+	maxAge  time.Duration
+	level   int
+	handler http.Handler
+}
+
+// RequireFreshAuth returns a middleware that only passes requests whose named
+// session authenticated with at least level within maxAge, see RecordAuth.
+// Requests without a stored session are never fresh. Other requests are
+// answered with 401 Unauthorized by default. If reading the
+// authentication events fails, the request is answered with 500 Internal
+// Server Error.
+func RequireFreshAuth(store *Store, name string, maxAge time.Duration, level int, options ...FreshAuthOption) func(http.Handler) http.Handler {
+	f := &freshAuth{
+		store:   store,
+		name:    name,
+		maxAge:  maxAge,
+		level:   level,
+		handler: http.HandlerFunc(unauthorized),
+	}
+
+	for _, option := range options {
+		option(f)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Invalid cookies yield a new session without events.
+			session, _ := f.store.Get(r, f.name)
+			if session.IsNew || session.ID == "" {
+				f.handler.ServeHTTP(w, r)
+				return
+			}
+
+			fresh, err := f.store.FreshAuth(r, session, f.maxAge, f.level)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if !fresh {
+				f.handler.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,155 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRecordAuth(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	res := httptest.NewRecorder()
+	session, _ := store.New(req, "session")
+
+	// Recording an event for a new session saves it.
+	assert.NoError(t, store.RecordAuth(req, res, session, "password", 1))
+	assert.Len(t, res.Result().Cookies(), 1)
+	assert.Empty(t, session.Values)
+
+	assert.NoError(t, store.RecordAuth(req, res, session, "webauthn", 2))
+
+	events, err := store.AuthEvents(req, session)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "password", events[0].Method)
+	assert.Equal(t, 2, events[1].Level)
+	assert.WithinDuration(t, time.Now(), events[1].Time, time.Second)
+
+	fresh, err := store.FreshAuth(req, session, time.Minute, 2)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	fresh, err = store.FreshAuth(req, session, time.Minute, 3)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+
+	for i := 0; i < maxAuthEvents; i++ {
+		assert.NoError(t, store.RecordAuth(req, res, session, "password", 1))
+	}
+	events, err = store.AuthEvents(req, session)
+	assert.NoError(t, err)
+	assert.Len(t, events, maxAuthEvents)
+
+	// Sessions without an ID have no events.
+	events, err = store.AuthEvents(req, sessions.NewSession(store, "other"))
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestRequireFreshAuth(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	res := httptest.NewRecorder()
+	session, _ := store.New(req, "session")
+	assert.NoError(t, store.RecordAuth(req, res, session, "password", 1))
+	cookie := res.Result().Cookies()[0]
+
+	setEvents := func(events ...AuthEvent) {
+		b, _ := json.Marshal(events)
+		client.data[store.SessionKey(session.ID)+":auth"] = b
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name    string
+		events  []AuthEvent
+		options []FreshAuthOption
+		cookie  bool
+		status  int
+	}{
+		{"fresh", []AuthEvent{{Time: time.Now().Add(-time.Minute), Method: "password", Level: 1}}, nil, true, http.StatusNoContent},
+		{"stale", []AuthEvent{{Time: time.Now().Add(-time.Hour), Method: "password", Level: 1}}, nil, true, http.StatusUnauthorized},
+		{"level too low", []AuthEvent{{Time: time.Now(), Method: "password", Level: 0}}, nil, true, http.StatusUnauthorized},
+		{"future", []AuthEvent{{Time: time.Now().Add(time.Hour), Method: "password", Level: 1}}, nil, true, http.StatusUnauthorized},
+		{"no session", nil, nil, false, http.StatusUnauthorized},
+		{"redirect", nil, []FreshAuthOption{WithStepUpRedirect("/reauth")}, true, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEvents(tt.events...)
+
+			req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			res := httptest.NewRecorder()
+
+			RequireFreshAuth(store, "session", 5*time.Minute, 1, tt.options...)(next).ServeHTTP(res, req)
+			assert.Equal(t, tt.status, res.Code)
+		})
+	}
+}
+
+func TestRequireFreshAuthAfterLogout(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	login := func(t *testing.T) (*sessions.Session, *http.Cookie) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		res := httptest.NewRecorder()
+		session, _ := store.New(req, "session")
+		assert.NoError(t, store.Save(req, res, session))
+		assert.NoError(t, store.RecordAuth(req, res, session, "password", 1))
+
+		return session, res.Result().Cookies()[0]
+	}
+
+	get := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+		req.AddCookie(cookie)
+		res := httptest.NewRecorder()
+		RequireFreshAuth(store, "session", 5*time.Minute, 1)(next).ServeHTTP(res, req)
+
+		return res.Code
+	}
+
+	t.Run("logout", func(t *testing.T) {
+		session, cookie := login(t)
+		assert.Equal(t, http.StatusNoContent, get(cookie))
+
+		session.Options.MaxAge = -1
+		assert.NoError(t, store.Save(httptest.NewRequest(http.MethodPost, "/logout", nil), httptest.NewRecorder(), session))
+		assert.Empty(t, client.data)
+
+		assert.Equal(t, http.StatusUnauthorized, get(cookie))
+	})
+
+	t.Run("events without session", func(t *testing.T) {
+		session, cookie := login(t)
+
+		// Events that outlive their session are ignored.
+		delete(client.data, store.SessionKey(session.ID))
+		assert.Contains(t, client.data, store.SessionKey(session.ID)+":auth")
+
+		assert.Equal(t, http.StatusUnauthorized, get(cookie))
+	})
+}
@@ -3,8 +3,12 @@ package redisstore
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/securecookie"
@@ -21,6 +25,38 @@ type Client interface {
 	Del(ctx context.Context, key string) error
 }
 
+// ErrNotFound is returned by clients if a key does not exist.
+var ErrNotFound = errors.New("redisstore: key not found")
+
+// KeyScanner is an optional Client capability to iterate over keys.
+type KeyScanner interface {
+	// Scan returns a page of keys matching the glob pattern match and the
+	// cursor of the next page. Iteration starts and ends with cursor 0.
+	Scan(ctx context.Context, cursor uint64, match string, count int64) ([]string, uint64, error)
+}
+
+// TTLReader is an optional Client capability to read the expiration of keys.
+type TTLReader interface {
+	// TTL returns the remaining time to live of a key, a negative duration if
+	// the key does not expire or ErrNotFound if the key does not exist.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// GetDeleter is an optional Client capability to read and delete a key in a
+// single atomic operation, e.g. with GETDEL.
+type GetDeleter interface {
+	// GetDel returns the value of a key and deletes it. It returns ErrNotFound
+	// if the key does not exist.
+	GetDel(ctx context.Context, key string) ([]byte, error)
+}
+
+// MultiDeleter is an optional Client capability to delete several keys in a
+// single round trip.
+type MultiDeleter interface {
+	// DelMany deletes keys. Missing keys are ignored.
+	DelMany(ctx context.Context, keys ...string) error
+}
+
 // KeyGenFunc defines a function used by store to generate the session key.
 type KeyGenFunc func() string
 
@@ -31,6 +67,19 @@ type Store struct {
 	serializer SessionSerializer
 	keyGen     KeyGenFunc
 	keyPrefix  string
+	hashTags   bool
+	binding    *Binding
+
+	cookieCodec CookieCodec
+	transports  []Transport
+
+	eventHandlers []EventHandler
+
+	// batches holds the sessions read ahead by LoadMany per request registry.
+	batches sync.Map
+
+	mu           sync.RWMutex
+	capabilities Capabilities
 }
 
 var _ sessions.Store = (*Store)(nil)
@@ -44,6 +93,23 @@ func WithKeyPrefix(prefix string) Options {
 	}
 }
 
+// WithClusterHashTags wraps the session ID of all redis keys in a hash tag,
+// e.g. "session_{id}", so that every key of a session is stored in the same
+// redis cluster slot and can be used in a single multi-key command. The key
+// prefix must not contain curly braces.
+func WithClusterHashTags() Options {
+	return func(s *Store) {
+		s.hashTags = true
+	}
+}
+
+// WithKeyRing signs and verifies cookies with the keys of ring instead of
+// Codecs. Cookies signed with a verify-only key are re-signed with the active
+// key on the next Save. It is a shorthand for WithCookieCodec(ring).
+func WithKeyRing(ring *KeyRing) Options {
+	return WithCookieCodec(ring)
+}
+
 // WithSerializer sets the serializer used to serialize the session.
 // By default, the GobSerializer is used.
 func WithSerializer(serializer SessionSerializer) Options {
@@ -53,7 +119,8 @@ func WithSerializer(serializer SessionSerializer) Options {
 }
 
 // WithKeyGenerator sets the key generator used to generate the session key.
-// By default, the defaultKeyGenerator method is used.
+// By default, the defaultKeyGenerator method is used. Generated IDs must not
+// contain ':', '{' or '}'.
 func WithKeyGenerator(keyGen KeyGenFunc) Options {
 	return func(s *Store) {
 		s.keyGen = keyGen
@@ -82,8 +149,20 @@ func New(client Client, keyPairs [][]byte, options ...Options) *Store {
 		},
 		client:     client,
 		keyPrefix:  defaultKeyPrefix,
+		hashTags:   false,
+		binding:    nil,
 		keyGen:     defaultKeyGenerator,
 		serializer: GobSerializer{},
+
+		cookieCodec: nil,
+		transports:  []Transport{CookieTransport{}},
+
+		eventHandlers: nil,
+
+		batches: sync.Map{},
+
+		mu:           sync.RWMutex{},
+		capabilities: defaultCapabilities,
 	}
 
 	for _, option := range options {
@@ -104,11 +183,150 @@ func (s *Store) SetMaxAge(age int) {
 	s.Options.MaxAge = age
 
 	// Set the maxAge for each securecookie instance.
-	for _, codec := range s.Codecs {
-		if sc, ok := codec.(*securecookie.SecureCookie); ok {
-			sc.MaxAge(age)
+	SecureCookieCodec{Codecs: s.Codecs}.SetMaxAge(age)
+
+	if codec, ok := s.cookieCodec.(interface{ SetMaxAge(age int) }); ok {
+		codec.SetMaxAge(age)
+	}
+}
+
+// KeyPrefix returns the prefix of all session keys written by the store.
+func (s *Store) KeyPrefix() string {
+	return s.keyPrefix
+}
+
+// SessionKey returns the redis key of the session with the given ID.
+func (s *Store) SessionKey(id string) string {
+	if s.hashTags {
+		return s.keyPrefix + "{" + id + "}"
+	}
+
+	return s.keyPrefix + id
+}
+
+// SessionID returns the session ID of a redis key written by the store. It
+// reports false for other keys, including the auxiliary keys of a session.
+func (s *Store) SessionID(key string) (string, bool) {
+	id := strings.TrimPrefix(key, s.keyPrefix)
+	if len(id) == len(key) || id == "" {
+		return "", false
+	}
+
+	if s.hashTags {
+		if len(id) < 3 || id[0] != '{' || id[len(id)-1] != '}' {
+			return "", false
 		}
+		id = id[1 : len(id)-1]
 	}
+
+	if strings.ContainsAny(id, auxKeySeparator+"{}") {
+		return "", false
+	}
+
+	return id, true
+}
+
+// auxKeySeparator separates the session key from the name of an auxiliary key.
+const auxKeySeparator = ":"
+
+// auxKey returns the key of auxiliary data stored next to a session, e.g.
+// "session_{id}:flash". With cluster hash tags, it shares the slot of the
+// session key.
+func (s *Store) auxKey(id, name string) string {
+	return s.SessionKey(id) + auxKeySeparator + name
+}
+
+// auxIndexName is the name of the auxiliary key that lists the names of the
+// other auxiliary keys of a session, so that they are deleted with it.
+const auxIndexName = "aux"
+
+// setAux writes an auxiliary key of a session and records it in the index of
+// the session. The index is written first, so a failure never leaves an
+// auxiliary key that outlives a deleted session. Names written concurrently
+// to the same session may be missing from the index.
+func (s *Store) setAux(ctx context.Context, id, name string, value []byte, expiration time.Duration) error {
+	names, err := s.auxNames(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !containsString(names, name) {
+		names = append(names, name)
+	}
+
+	b, err := json.Marshal(names)
+	if err != nil {
+		return fmt.Errorf("encoding auxiliary keys: %v", err)
+	}
+
+	if err := s.client.Set(ctx, s.auxKey(id, auxIndexName), b, expiration); err != nil {
+		return fmt.Errorf("setting auxiliary keys: %v", err)
+	}
+
+	if err := s.client.Set(ctx, s.auxKey(id, name), value, expiration); err != nil {
+		return fmt.Errorf("setting %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// auxNames returns the names of the auxiliary keys recorded by setAux.
+func (s *Store) auxNames(ctx context.Context, id string) ([]string, error) {
+	val, err := s.client.Get(ctx, s.auxKey(id, auxIndexName))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting auxiliary keys: %v", err)
+	}
+
+	var names []string
+	if err := json.Unmarshal(val, &names); err != nil {
+		return nil, fmt.Errorf("decoding auxiliary keys: %v", err)
+	}
+
+	return names, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// auxSessionID returns the session ID of an auxiliary key written by the
+// store. It reports false for other keys, including session keys.
+func (s *Store) auxSessionID(key string) (string, bool) {
+	rest := strings.TrimPrefix(key, s.keyPrefix)
+	if len(rest) == len(key) {
+		return "", false
+	}
+
+	i := strings.Index(rest, auxKeySeparator)
+	if i < 0 {
+		return "", false
+	}
+
+	return s.SessionID(key[:len(s.keyPrefix)+i])
+}
+
+// sessionKeyPattern returns a glob pattern matching the keys of all sessions
+// whose ID starts with idPrefix. With aux, the pattern also matches their
+// auxiliary keys.
+func (s *Store) sessionKeyPattern(idPrefix string, aux bool) string {
+	if s.hashTags {
+		if aux {
+			return escapeGlob(s.keyPrefix+"{"+idPrefix) + "*}*"
+		}
+
+		return escapeGlob(s.keyPrefix+"{"+idPrefix) + "*}"
+	}
+
+	return escapeGlob(s.keyPrefix+idPrefix) + "*"
 }
 
 // SetOptions sets the options for the store.
@@ -132,16 +350,24 @@ func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
 	session.Options = &options
 	session.IsNew = true
 
-	c, errCookie := r.Cookie(name)
-	if errCookie != nil {
-		return session, nil //nolint: nilerr
+	value, _, ok := s.readID(r, name)
+	if !ok {
+		return session, nil
 	}
 
-	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+	id, err := s.codec().Decode(name, value)
+	if err != nil {
+		s.emit(SessionRejected, r, session, nil, err)
 		return session, fmt.Errorf("redisstore(new): decoding cookie value: %v", err)
 	}
 
-	if err := s.load(r.Context(), session); err == nil {
+	session.ID = id
+
+	if err := s.loadRequest(r, session); err != nil {
+		s.emit(SessionRejected, r, session, nil, err)
+		// Keys left next to a missing session must not be used with it.
+		session.ID = ""
+	} else if (s.binding == nil || s.checkBinding(r, session)) && s.checkImpersonation(r, session) {
 		session.IsNew = false
 	}
 
@@ -150,15 +376,19 @@ func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
 
 // Save adds a single session to the response.
 //
-// If the Options.MaxAge of the session is <= 0, the session is deleted.
+// If the Options.MaxAge of the session is <= 0, the session is deleted. The
+// max age of an impersonated session is limited to its impersonation.
 func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	capImpersonation(session)
+
 	// Delete session if max-age is <= 0
 	if session.Options.MaxAge <= 0 {
 		// TODO(joelrose): find a better solution, not sure if we should use the request context here
 		if err := s.delete(r.Context(), session); err != nil {
 			return fmt.Errorf("redisstore(save): deleting session: %v", err)
 		}
-		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		s.writeID(r, w, session, "")
+		s.emit(SessionDeleted, r, session, nil, nil)
 
 		return nil
 	}
@@ -167,20 +397,73 @@ func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.S
 		session.ID = s.keyGen()
 	}
 
+	s.bind(r, session)
+
+	var previous *sessions.Session
+	if len(s.eventHandlers) > 0 {
+		previous = s.previous(r.Context(), session)
+	}
+
 	if err := s.save(r.Context(), session); err != nil {
 		return fmt.Errorf("redisstore(save): saving session: %v", err)
 	}
 
-	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	return s.saved(r, w, session, previous)
+}
+
+// saved sends the ID of a stored session to the client and emits its event.
+// previous is the state before saving or nil if the session was created.
+func (s *Store) saved(r *http.Request, w http.ResponseWriter, session, previous *sessions.Session) error {
+	encoded, err := s.codec().Encode(session.Name(), session.ID)
 	if err != nil {
 		return fmt.Errorf("redisstore(save): encoding cookie value: %v", err)
 	}
 
-	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	s.writeID(r, w, session, encoded)
+
+	if len(s.eventHandlers) > 0 {
+		if previous == nil {
+			s.emit(SessionCreated, r, session, changedKeys(s.serializer, nil, session.Values), nil)
+		} else {
+			s.emit(SessionSaved, r, session, changedKeys(s.serializer, previous.Values, session.Values), nil)
+		}
+	}
 
 	return nil
 }
 
+// Regenerate replaces the ID of a session while keeping its values, which
+// prevents session fixation after a privilege change such as a login. The
+// session stored under the old ID is deleted with its auxiliary keys, e.g.
+// flash messages, and the new one is saved. Impersonated sessions are not
+// regenerated and ErrImpersonating is returned.
+func (s *Store) Regenerate(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if _, ok := Impersonating(session); ok {
+		return ErrImpersonating
+	}
+
+	if session.ID != "" {
+		if err := s.delete(r.Context(), session); err != nil {
+			return fmt.Errorf("redisstore(regenerate): deleting session: %v", err)
+		}
+		s.emit(SessionDeleted, r, session, nil, nil)
+	}
+
+	session.ID = ""
+	session.IsNew = true
+
+	return s.Save(r, w, session)
+}
+
+// codec returns the codec of session cookies.
+func (s *Store) codec() CookieCodec {
+	if s.cookieCodec != nil {
+		return s.cookieCodec
+	}
+
+	return SecureCookieCodec{Codecs: s.Codecs}
+}
+
 // save stores the session in redis.
 func (s *Store) save(ctx context.Context, session *sessions.Session) error {
 	b, err := s.serializer.Serialize(session)
@@ -189,8 +472,7 @@ func (s *Store) save(ctx context.Context, session *sessions.Session) error {
 	}
 
 	maxAge := time.Duration(session.Options.MaxAge) * time.Second
-	key := s.keyPrefix + session.ID
-	if err := s.client.Set(ctx, key, b, maxAge); err != nil {
+	if err := s.client.Set(ctx, s.SessionKey(session.ID), b, maxAge); err != nil {
 		return fmt.Errorf("setting session: %v", err)
 	}
 
@@ -199,11 +481,16 @@ func (s *Store) save(ctx context.Context, session *sessions.Session) error {
 
 // load reads the session from redis.
 func (s *Store) load(ctx context.Context, session *sessions.Session) error {
-	val, err := s.client.Get(ctx, s.keyPrefix+session.ID)
+	val, err := s.client.Get(ctx, s.SessionKey(session.ID))
 	if err != nil {
 		return fmt.Errorf("getting session: %v", err)
 	}
 
+	return s.deserialize(val, session)
+}
+
+// deserialize decodes a stored session value into session.
+func (s *Store) deserialize(val []byte, session *sessions.Session) error {
 	if err := s.serializer.Deserialize(val, session); err != nil {
 		return fmt.Errorf("deserializing session: %v", err)
 	}
@@ -211,15 +498,78 @@ func (s *Store) load(ctx context.Context, session *sessions.Session) error {
 	return nil
 }
 
-// delete removes session from redis.
+// previous reads the currently stored state of a session. It returns nil if
+// the session does not exist yet.
+func (s *Store) previous(ctx context.Context, session *sessions.Session) *sessions.Session {
+	previous := sessions.NewSession(s, session.Name())
+	previous.ID = session.ID
+
+	if err := s.load(ctx, previous); err != nil {
+		return nil
+	}
+
+	return previous
+}
+
+// delete removes session and its auxiliary keys from redis.
 func (s *Store) delete(ctx context.Context, session *sessions.Session) error {
-	if err := s.client.Del(ctx, s.keyPrefix+session.ID); err != nil {
+	return s.deleteID(ctx, session.ID)
+}
+
+// deleteID removes the session with the given ID and its auxiliary keys from
+// redis.
+func (s *Store) deleteID(ctx context.Context, id string) error {
+	keys, err := s.sessionKeys(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.del(ctx, keys...); err != nil {
 		return fmt.Errorf("deleting session: %v", err)
 	}
 
 	return nil
 }
 
+// sessionKeys returns the key of the session with the given ID and its
+// auxiliary keys.
+func (s *Store) sessionKeys(ctx context.Context, id string) ([]string, error) {
+	names, err := s.auxNames(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{s.SessionKey(id)}
+	if len(names) > 0 {
+		for _, name := range names {
+			keys = append(keys, s.auxKey(id, name))
+		}
+		keys = append(keys, s.auxKey(id, auxIndexName))
+	}
+
+	return keys, nil
+}
+
+// del deletes keys, in a single round trip if the client implements
+// MultiDeleter.
+func (s *Store) del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	if deleter, ok := s.client.(MultiDeleter); ok {
+		return deleter.DelMany(ctx, keys...) //nolint: wrapcheck
+	}
+
+	for _, key := range keys {
+		if err := s.client.Del(ctx, key); err != nil {
+			return err //nolint: wrapcheck
+		}
+	}
+
+	return nil
+}
+
 // defaultKeyGenerator generates a new session ID.
 func defaultKeyGenerator() string {
 	return xid.New().String()
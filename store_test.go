@@ -21,6 +21,12 @@ func TestOptions(t *testing.T) {
 		assert.Equal(t, "prefix_", store.keyPrefix)
 	})
 
+	t.Run("WithClusterHashTags", func(t *testing.T) {
+		store := &Store{}
+		WithClusterHashTags()(store)
+		assert.True(t, store.hashTags)
+	})
+
 	t.Run("WithSerializer_Gob", func(t *testing.T) {
 		store := &Store{}
 		WithSerializer(GobSerializer{})(store)
@@ -156,6 +162,7 @@ func TestStore_Delete(t *testing.T) {
 		return nil
 	})
 
+	client.EXPECT().Get(gomock.Any(), "prefix_key:aux").Return(nil, ErrNotFound)
 	client.EXPECT().Del(gomock.Any(), "prefix_key").Return(nil)
 
 	keyPairs := [][]byte{[]byte("key")}
@@ -196,6 +203,39 @@ func TestStore_Delete(t *testing.T) {
 	}
 }
 
+func TestStoreSessionKey(t *testing.T) {
+	plain := New(nil, nil)
+	tagged := New(nil, nil, WithClusterHashTags())
+
+	assert.Equal(t, "session_abc", plain.SessionKey("abc"))
+	assert.Equal(t, "session_abc:flash", plain.auxKey("abc", "flash"))
+	assert.Equal(t, "session_{abc}", tagged.SessionKey("abc"))
+	assert.Equal(t, "session_{abc}:flash", tagged.auxKey("abc", "flash"))
+
+	tests := []struct {
+		store *Store
+		key   string
+		id    string
+		ok    bool
+	}{
+		{store: plain, key: "session_abc", id: "abc", ok: true},
+		{store: plain, key: "session_", ok: false},
+		{store: plain, key: "other_abc", ok: false},
+		{store: plain, key: "session_abc:flash", ok: false},
+		{store: plain, key: "session_{abc}", ok: false},
+		{store: tagged, key: "session_{abc}", id: "abc", ok: true},
+		{store: tagged, key: "session_{}", ok: false},
+		{store: tagged, key: "session_abc", ok: false},
+		{store: tagged, key: "session_{abc}:flash", ok: false},
+	}
+
+	for _, tt := range tests {
+		id, ok := tt.store.SessionID(tt.key)
+		assert.Equal(t, tt.ok, ok, tt.key)
+		assert.Equal(t, tt.id, id, tt.key)
+	}
+}
+
 func TestDefaultKeyGenerator(t *testing.T) {
 	t.Run("generates a unique key", func(t *testing.T) {
 		key1 := defaultKeyGenerator()
@@ -0,0 +1,315 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WritePolicy defines how a TieredClient writes to the tiers after the first.
+type WritePolicy int
+
+const (
+	// WriteThrough writes every tier before Set returns.
+	WriteThrough WritePolicy = iota
+	// WriteBehind writes the first tier before Set returns and the other
+	// tiers in the background.
+	WriteBehind
+)
+
+// TieredClient is a Client on top of an ordered list of clients, e.g. a local
+// memory client, redis and a durable SQL client. Reads are served by the first
+// tier that has the key and copied to the tiers before it. Writes go to all
+// tiers according to the WritePolicy. Deletes always remove the key from all
+// tiers before returning. Process local tiers are not invalidated when other
+// processes change a key, so they should use short expirations.
+type TieredClient struct {
+	tiers       []Client
+	policy      WritePolicy
+	backfillTTL time.Duration
+	onError     func(err error)
+
+	queue chan tieredWrite
+	done  chan struct{}
+
+	mu      sync.RWMutex
+	closed  bool
+	seq     uint64
+	pending map[string]uint64
+
+	// flushMu serializes background writes with deletes, so that a queued
+	// write never restores a deleted key.
+	flushMu sync.Mutex
+}
+
+var _ Client = (*TieredClient)(nil)
+
+// tieredWrite is a write queued for the tiers after the first.
+type tieredWrite struct {
+	key        string
+	value      interface{}
+	expiration time.Duration
+	seq        uint64
+}
+
+// TieredOption configures a TieredClient.
+type TieredOption func(c *TieredClient)
+
+// WithWritePolicy sets the write policy. By default, WriteThrough is used.
+func WithWritePolicy(policy WritePolicy) TieredOption {
+	return func(c *TieredClient) {
+		c.policy = policy
+	}
+}
+
+// WithWriteBehindQueue sets the number of writes that WriteBehind queues. If
+// the queue is full, Set writes all tiers itself. By default, 1024 writes are
+// queued.
+func WithWriteBehindQueue(size int) TieredOption {
+	return func(c *TieredClient) {
+		c.queue = make(chan tieredWrite, size)
+	}
+}
+
+// WithBackfillTTL sets the expiration of keys copied to earlier tiers if the
+// tier that served the read does not implement TTLReader. By default, such
+// keys are not copied.
+func WithBackfillTTL(ttl time.Duration) TieredOption {
+	return func(c *TieredClient) {
+		c.backfillTTL = ttl
+	}
+}
+
+// WithTierErrorHandler sets a function that is called with the errors of
+// tiers that did not fail the operation, e.g. an unavailable tier that a read
+// skipped or a failed background write.
+func WithTierErrorHandler(fn func(err error)) TieredOption {
+	return func(c *TieredClient) {
+		c.onError = fn
+	}
+}
+
+const defaultWriteBehindQueue = 1024
+
+// NewTieredClient returns a TieredClient for tiers, ordered from the fastest
+// to the most durable one. With WriteBehind, a goroutine writes the queued
+// values until Close is called.
+func NewTieredClient(tiers []Client, options ...TieredOption) *TieredClient {
+	c := &TieredClient{
+		tiers:       tiers,
+		policy:      WriteThrough,
+		backfillTTL: 0,
+		onError:     func(error) {},
+		queue:       make(chan tieredWrite, defaultWriteBehindQueue),
+		done:        make(chan struct{}),
+		mu:          sync.RWMutex{},
+		closed:      false,
+		seq:         0,
+		pending:     make(map[string]uint64),
+		flushMu:     sync.Mutex{},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.policy == WriteBehind {
+		go c.run()
+	} else {
+		close(c.done)
+	}
+
+	return c
+}
+
+// Get returns the value of the first tier that has the key. Tiers that fail
+// are skipped. ErrNotFound is only returned if every tier reported the key as
+// missing.
+func (c *TieredClient) Get(ctx context.Context, key string) ([]byte, error) {
+	var errs []error
+
+	for i, tier := range c.tiers {
+		val, err := tier.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tier %d: %w", i, err))
+			continue
+		}
+
+		for _, err := range errs {
+			c.onError(fmt.Errorf("redisstore(tiered): getting key: %v", err))
+		}
+
+		if i > 0 {
+			c.backfill(ctx, key, val, tier, c.tiers[:i])
+		}
+
+		return val, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("redisstore(tiered): getting key: %w", errors.Join(errs...))
+	}
+
+	return nil, ErrNotFound
+}
+
+// backfill copies a value read from tier to the earlier tiers.
+func (c *TieredClient) backfill(ctx context.Context, key string, val []byte, tier Client, earlier []Client) {
+	ttl := c.backfillTTL
+	if reader, ok := tier.(TTLReader); ok {
+		t, err := reader.TTL(ctx, key)
+		if err != nil {
+			c.onError(fmt.Errorf("redisstore(tiered): getting ttl: %v", err))
+			return
+		}
+		// A negative TTL means that the key does not expire.
+		ttl = t
+		if t < 0 {
+			ttl = 0
+		}
+	} else if ttl <= 0 {
+		return
+	}
+
+	for i, t := range earlier {
+		if err := t.Set(ctx, key, val, ttl); err != nil {
+			c.onError(fmt.Errorf("redisstore(tiered): backfilling tier %d: %v", i, err))
+		}
+	}
+}
+
+// Set writes the value to all tiers. With WriteBehind, only the first tier is
+// written before Set returns.
+func (c *TieredClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if c.policy == WriteThrough || len(c.tiers) < 2 {
+		return c.setAll(ctx, c.tiers, key, value, expiration)
+	}
+
+	if err := c.tiers[0].Set(ctx, key, value, expiration); err != nil {
+		return fmt.Errorf("redisstore(tiered): setting key: tier 0: %w", err)
+	}
+
+	c.mu.Lock()
+	if !c.closed {
+		c.seq++
+		write := tieredWrite{key: key, value: value, expiration: expiration, seq: c.seq}
+
+		select {
+		case c.queue <- write:
+			c.pending[key] = c.seq
+			c.mu.Unlock()
+
+			return nil
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	// The queue is full or closed, so write the other tiers directly. A
+	// queued write of the key must not overwrite this value later.
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	return c.setAll(ctx, c.tiers[1:], key, value, expiration)
+}
+
+// setAll writes the value to every tier in tiers.
+func (c *TieredClient) setAll(ctx context.Context, tiers []Client, key string, value interface{}, expiration time.Duration) error {
+	offset := len(c.tiers) - len(tiers)
+
+	var errs []error
+	for i, tier := range tiers {
+		if err := tier.Set(ctx, key, value, expiration); err != nil {
+			errs = append(errs, fmt.Errorf("tier %d: %w", offset+i, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("redisstore(tiered): setting key: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// Del deletes the key from all tiers, including values that are still queued
+// for a background write.
+func (c *TieredClient) Del(ctx context.Context, key string) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	var errs []error
+	for i, tier := range c.tiers {
+		if err := tier.Del(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("tier %d: %w", i, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("redisstore(tiered): deleting key: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// run writes the queued values to the tiers after the first.
+func (c *TieredClient) run() {
+	defer close(c.done)
+
+	for write := range c.queue {
+		c.flush(write)
+	}
+}
+
+// flush writes a queued value unless the key was deleted or written again
+// since it was queued.
+func (c *TieredClient) flush(write tieredWrite) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.RLock()
+	current := c.pending[write.key] == write.seq
+	c.mu.RUnlock()
+
+	if !current {
+		return
+	}
+
+	// The context of the request that queued the write may be done already.
+	if err := c.setAll(context.Background(), c.tiers[1:], write.key, write.value, write.expiration); err != nil {
+		c.onError(err)
+	}
+
+	c.mu.Lock()
+	if c.pending[write.key] == write.seq {
+		delete(c.pending, write.key)
+	}
+	c.mu.Unlock()
+}
+
+// Close stops the background writer and waits until all queued values have
+// been written. Later writes go to all tiers before Set returns.
+func (c *TieredClient) Close() {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		if c.policy == WriteBehind {
+			close(c.queue)
+		}
+	}
+	c.mu.Unlock()
+
+	<-c.done
+}
@@ -0,0 +1,231 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tierClient is a concurrency safe map based Client that records the
+// expiration of every key.
+type tierClient struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	ttls map[string]time.Duration
+	err  error
+	// block delays Set until it is closed.
+	block chan struct{}
+}
+
+func newTierClient() *tierClient {
+	return &tierClient{data: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (c *tierClient) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	val, ok := c.data[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return val, nil
+}
+
+func (c *tierClient) Set(_ context.Context, key string, value interface{}, expiration time.Duration) error {
+	if c.block != nil {
+		<-c.block
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	c.data[key] = value.([]byte)
+	c.ttls[key] = expiration
+
+	return nil
+}
+
+func (c *tierClient) Del(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	delete(c.data, key)
+
+	return nil
+}
+
+func (c *tierClient) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.data[key]
+
+	return ok
+}
+
+// ttlTierClient is a tierClient implementing TTLReader.
+type ttlTierClient struct {
+	*tierClient
+}
+
+func (c ttlTierClient) TTL(_ context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.data[key]; !ok {
+		return 0, ErrNotFound
+	}
+
+	return c.ttls[key], nil
+}
+
+func TestTieredClient(t *testing.T) {
+	ctx := context.Background()
+	errDown := errors.New("down")
+
+	t.Run("write through", func(t *testing.T) {
+		memory, redis, sql := newTierClient(), newTierClient(), newTierClient()
+		c := NewTieredClient([]Client{memory, redis, sql})
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+		assert.True(t, memory.has("key"))
+		assert.True(t, redis.has("key"))
+		assert.True(t, sql.has("key"))
+
+		assert.NoError(t, c.Del(ctx, "key"))
+		assert.False(t, memory.has("key"))
+		assert.False(t, sql.has("key"))
+
+		sql.err = errDown
+		err := c.Set(ctx, "key", []byte("value"), time.Minute)
+		assert.ErrorIs(t, err, errDown)
+		assert.Contains(t, err.Error(), "tier 2")
+	})
+
+	t.Run("read through", func(t *testing.T) {
+		memory, redis := newTierClient(), newTierClient()
+		sql := ttlTierClient{newTierClient()}
+		c := NewTieredClient([]Client{memory, redis, sql})
+		defer c.Close()
+
+		assert.NoError(t, sql.Set(ctx, "key", []byte("value"), time.Hour))
+
+		// A flushed redis is refilled from the durable tier.
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+		assert.True(t, memory.has("key"))
+		assert.Equal(t, time.Hour, redis.ttls["key"])
+
+		_, err = c.Get(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("backfill ttl", func(t *testing.T) {
+		memory, redis := newTierClient(), newTierClient()
+		assert.NoError(t, redis.Set(ctx, "key", []byte("value"), 0))
+
+		c := NewTieredClient([]Client{memory, redis})
+		_, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.False(t, memory.has("key"))
+
+		c = NewTieredClient([]Client{memory, redis}, WithBackfillTTL(time.Second))
+		_, err = c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Second, memory.ttls["key"])
+	})
+
+	t.Run("unavailable tier", func(t *testing.T) {
+		redis, sql := newTierClient(), newTierClient()
+		var errs []error
+		c := NewTieredClient([]Client{redis, sql}, WithTierErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}))
+		defer c.Close()
+
+		assert.NoError(t, sql.Set(ctx, "key", []byte("value"), time.Minute))
+		redis.err = errDown
+
+		val, err := c.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("value"), val)
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "tier 0: down")
+
+		_, err = c.Get(ctx, "missing")
+		assert.ErrorIs(t, err, errDown)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("write behind", func(t *testing.T) {
+		redis, sql := newTierClient(), newTierClient()
+		sql.block = make(chan struct{})
+		c := NewTieredClient([]Client{redis, sql}, WithWritePolicy(WriteBehind))
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("v1"), time.Minute))
+		assert.True(t, redis.has("key"))
+		assert.False(t, sql.has("key"))
+
+		close(sql.block)
+		c.Close()
+		assert.True(t, sql.has("key"))
+
+		// After Close, writes are synchronous.
+		assert.NoError(t, c.Set(ctx, "key", []byte("v2"), time.Minute))
+		val, _ := sql.Get(ctx, "key")
+		assert.Equal(t, []byte("v2"), val)
+	})
+
+	t.Run("write behind queue full", func(t *testing.T) {
+		redis, sql := newTierClient(), newTierClient()
+		c := NewTieredClient([]Client{redis, sql}, WithWritePolicy(WriteBehind), WithWriteBehindQueue(0))
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+		assert.True(t, sql.has("key"))
+	})
+
+	t.Run("write behind delete", func(t *testing.T) {
+		redis, sql := newTierClient(), newTierClient()
+		c := NewTieredClient([]Client{redis, sql}, WithWritePolicy(WriteBehind))
+
+		// Block the writer with another key so that "key" stays queued.
+		sql.block = make(chan struct{})
+		assert.NoError(t, c.Set(ctx, "other", []byte("value"), time.Minute))
+		assert.NoError(t, c.Set(ctx, "key", []byte("value"), time.Minute))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, c.Del(ctx, "key"))
+		}()
+
+		close(sql.block)
+		<-done
+		c.Close()
+
+		assert.False(t, redis.has("key"))
+		assert.False(t, sql.has("key"))
+		assert.True(t, sql.has("other"))
+	})
+}
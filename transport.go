@@ -0,0 +1,164 @@
+package redisstore
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// Transport carries the encoded session ID between client and server.
+type Transport interface {
+	// Read returns the encoded session ID of the named session sent with a
+	// request. It reports false if the request does not carry one.
+	Read(r *http.Request, name string) (string, bool)
+	// Write sends the encoded session ID of the named session to the client.
+	// An empty value deletes the session on the client.
+	Write(w http.ResponseWriter, name, value string, options *sessions.Options)
+}
+
+// WithTransports sets the transports that carry session IDs. Store.New uses
+// the first transport that finds a session ID in the request. Save writes the
+// session ID to the transport the request used or, for sessions the request
+// did not carry or that arrived with a QueryTransport, to all transports. By
+// default, only cookies are used.
+func WithTransports(transports ...Transport) Options {
+	return func(s *Store) {
+		s.transports = transports
+	}
+}
+
+// CookieTransport carries session IDs in cookies named after the session.
+type CookieTransport struct{}
+
+var _ Transport = CookieTransport{}
+
+// Read implements Transport.
+func (CookieTransport) Read(r *http.Request, name string) (string, bool) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+
+	return c.Value, true
+}
+
+// Write implements Transport.
+func (CookieTransport) Write(w http.ResponseWriter, name, value string, options *sessions.Options) {
+	http.SetCookie(w, sessions.NewCookie(name, value, options))
+}
+
+// HeaderTransport carries session IDs in a request header, e.g. for mobile
+// clients and single page applications. It carries a single session, the
+// session name is ignored.
+type HeaderTransport struct {
+	// Header is the request header that carries the session ID.
+	Header string
+	// Scheme is the authentication scheme in front of the session ID, e.g.
+	// "Bearer" for "Authorization: Bearer <id>". It is optional.
+	Scheme string
+	// ResponseHeader is the response header the session ID is written to. By
+	// default, Header is used.
+	ResponseHeader string
+}
+
+var _ Transport = HeaderTransport{}
+
+// BearerTransport returns a HeaderTransport that reads session IDs from the
+// Authorization header as bearer tokens and writes them to the
+// X-Session-Token response header.
+func BearerTransport() HeaderTransport {
+	return HeaderTransport{
+		Header:         "Authorization",
+		Scheme:         "Bearer",
+		ResponseHeader: "X-Session-Token",
+	}
+}
+
+// Read implements Transport.
+func (t HeaderTransport) Read(r *http.Request, _ string) (string, bool) {
+	value := r.Header.Get(t.Header)
+	if t.Scheme != "" {
+		scheme, token, ok := strings.Cut(value, " ")
+		if !ok || !strings.EqualFold(scheme, t.Scheme) {
+			return "", false
+		}
+		value = token
+	}
+
+	value = strings.TrimSpace(value)
+
+	return value, value != ""
+}
+
+// Write implements Transport.
+func (t HeaderTransport) Write(w http.ResponseWriter, _, value string, _ *sessions.Options) {
+	header := t.ResponseHeader
+	if header == "" {
+		header = t.Header
+	}
+
+	w.Header().Set(header, value)
+}
+
+// QueryTransport reads session IDs from a query parameter, e.g. for WebSocket
+// handshakes where browsers can not set headers. Session IDs are never written
+// to URLs, so it should be combined with another transport. Sessions read from
+// the query are saved to all other transports.
+type QueryTransport struct {
+	// Param is the query parameter that carries the session ID. By default,
+	// the session name is used.
+	Param string
+}
+
+var _ Transport = QueryTransport{}
+
+// Read implements Transport.
+func (t QueryTransport) Read(r *http.Request, name string) (string, bool) {
+	param := t.Param
+	if param == "" {
+		param = name
+	}
+
+	value := r.URL.Query().Get(param)
+
+	return value, value != ""
+}
+
+// Write implements Transport. It does nothing.
+func (QueryTransport) Write(http.ResponseWriter, string, string, *sessions.Options) {}
+
+// readID returns the encoded session ID of the named session and the
+// transport that carried it.
+func (s *Store) readID(r *http.Request, name string) (string, Transport, bool) {
+	for _, transport := range s.transports {
+		if value, ok := transport.Read(r, name); ok {
+			return value, transport, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// writeID sends the encoded session ID to the transport the request used or
+// to all transports if that transport can not write.
+func (s *Store) writeID(r *http.Request, w http.ResponseWriter, session *sessions.Session, value string) {
+	if _, transport, ok := s.readID(r, session.Name()); ok && canWrite(transport) {
+		transport.Write(w, session.Name(), value, session.Options)
+		return
+	}
+
+	for _, transport := range s.transports {
+		transport.Write(w, session.Name(), value, session.Options)
+	}
+}
+
+// canWrite reports whether a transport sends session IDs to the client.
+func canWrite(transport Transport) bool {
+	switch transport.(type) {
+	case QueryTransport, *QueryTransport:
+		return false
+	default:
+		return true
+	}
+}
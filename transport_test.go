@@ -0,0 +1,118 @@
+package redisstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderTransport(t *testing.T) {
+	transport := BearerTransport()
+
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Bearer token", "token", true},
+		{"bearer token", "token", true},
+		{"Basic token", "", false},
+		{"Bearer ", "", false},
+		{"token", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", tt.header)
+
+		value, ok := transport.Read(req, "session")
+		assert.Equal(t, tt.want, value, tt.header)
+		assert.Equal(t, tt.ok, ok, tt.header)
+	}
+
+	res := httptest.NewRecorder()
+	transport.Write(res, "session", "token", nil)
+	assert.Equal(t, "token", res.Header().Get("X-Session-Token"))
+
+	res = httptest.NewRecorder()
+	HeaderTransport{Header: "X-Session"}.Write(res, "session", "token", nil)
+	assert.Equal(t, "token", res.Header().Get("X-Session"))
+}
+
+func TestQueryTransport(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/ws?session=a&token=b", nil)
+
+	value, ok := QueryTransport{}.Read(req, "session")
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	value, ok = QueryTransport{Param: "token"}.Read(req, "session")
+	assert.True(t, ok)
+	assert.Equal(t, "b", value)
+
+	_, ok = QueryTransport{}.Read(req, "other")
+	assert.False(t, ok)
+}
+
+func TestStoreWithTransports(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")}, WithTransports(CookieTransport{}, BearerTransport(), QueryTransport{}))
+
+	// New sessions are written to all transports.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	session, _ := store.New(req, "session")
+	session.Values["user"] = "a"
+	assert.NoError(t, store.Save(req, res, session))
+
+	token := res.Header().Get("X-Session-Token")
+	assert.NotEmpty(t, token)
+	assert.Len(t, res.Result().Cookies(), 1)
+
+	t.Run("header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		session, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.False(t, session.IsNew)
+		assert.Equal(t, "a", session.Values["user"])
+
+		// Saving writes to the transport the request used only.
+		res := httptest.NewRecorder()
+		assert.NoError(t, store.Save(req, res, session))
+		assert.NotEmpty(t, res.Header().Get("X-Session-Token"))
+		assert.Empty(t, res.Result().Cookies())
+
+		session.Options.MaxAge = -1
+		res = httptest.NewRecorder()
+		assert.NoError(t, store.Save(req, res, session))
+		assert.Contains(t, res.Header(), "X-Session-Token")
+		assert.Empty(t, res.Header().Get("X-Session-Token"))
+		assert.Empty(t, client.data)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		session, _ := store.New(req, "session")
+		assert.NoError(t, store.Save(req, res, session))
+		token := res.Header().Get("X-Session-Token")
+
+		req = httptest.NewRequest(http.MethodGet, "/ws?session="+url.QueryEscape(token), nil)
+		loaded, err := store.New(req, "session")
+		assert.NoError(t, err)
+		assert.False(t, loaded.IsNew)
+		assert.Equal(t, session.ID, loaded.ID)
+
+		// The query can not carry the session back, so the other transports
+		// are written.
+		res = httptest.NewRecorder()
+		assert.NoError(t, store.Save(req, res, loaded))
+		assert.NotEmpty(t, res.Header().Get("X-Session-Token"))
+		assert.Len(t, res.Result().Cookies(), 1)
+	})
+}
@@ -0,0 +1,92 @@
+package redisstore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// Key is a typed accessor of a session value.
+//
+//	var userID = redisstore.NewKey[int64]("user_id")
+//
+//	userID.Set(session, 42)
+//	id, ok := userID.Get(session)
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns the accessor of the session value name.
+func NewKey[T any](name string) Key[T] {
+	return Key[T]{name: name}
+}
+
+// Name returns the name of the session value.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Get returns the value as T. Values of another type, e.g. numbers decoded as
+// float64 by JSONSerializer, are converted through their JSON representation.
+// It reports false if the value is missing or can not be converted.
+func (k Key[T]) Get(session *sessions.Session) (T, bool) {
+	var zero T
+
+	v, ok := session.Values[k.name]
+	if !ok {
+		return zero, false
+	}
+
+	t, err := convertValue[T](v)
+	if err != nil {
+		return zero, false
+	}
+
+	return t, true
+}
+
+// GetOr returns the value as T or def if it is missing or can not be
+// converted.
+func (k Key[T]) GetOr(session *sessions.Session, def T) T {
+	if t, ok := k.Get(session); ok {
+		return t
+	}
+
+	return def
+}
+
+// Set sets the value.
+func (k Key[T]) Set(session *sessions.Session, value T) {
+	session.Values[k.name] = value
+}
+
+// Delete removes the value.
+func (k Key[T]) Delete(session *sessions.Session) {
+	delete(session.Values, k.name)
+}
+
+// convertValue returns v as T, converting it through JSON if it has another
+// type.
+func convertValue[T any](v interface{}) (T, error) {
+	if t, ok := v.(T); ok {
+		return t, nil
+	}
+
+	var t T
+
+	if v == nil {
+		return t, fmt.Errorf("redisstore(typed): nil value")
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return t, fmt.Errorf("redisstore(typed): encoding value: %v", err)
+	}
+
+	if err := json.Unmarshal(b, &t); err != nil {
+		return t, fmt.Errorf("redisstore(typed): converting %T to %T: %v", v, t, err)
+	}
+
+	return t, nil
+}
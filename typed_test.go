@@ -0,0 +1,60 @@
+package redisstore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	serializers := []struct {
+		name       string
+		serializer SessionSerializer
+	}{
+		{"gob", GobSerializer{}},
+		{"json", JSONSerializer{}},
+	}
+	for _, tt := range serializers {
+		t.Run(tt.name, func(t *testing.T) {
+			store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")}, WithSerializer(tt.serializer))
+
+			userID := NewKey[int64]("user_id")
+			name := NewKey[string]("name")
+			assert.Equal(t, "user_id", userID.Name())
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+			session, _ := store.New(req, "session")
+
+			_, ok := userID.Get(session)
+			assert.False(t, ok)
+			assert.Equal(t, int64(7), userID.GetOr(session, 7))
+
+			userID.Set(session, 42)
+			name.Set(session, "a")
+			assert.NoError(t, store.Save(req, res, session))
+
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(res.Result().Cookies()[0])
+			loaded, err := store.New(req, "session")
+			assert.NoError(t, err)
+
+			// JSONSerializer decodes numbers as float64.
+			id, ok := userID.Get(loaded)
+			assert.True(t, ok)
+			assert.Equal(t, int64(42), id)
+			assert.Equal(t, "a", name.GetOr(loaded, ""))
+
+			// Values that can not be converted are reported as missing.
+			_, ok = NewKey[int64]("name").Get(loaded)
+			assert.False(t, ok)
+			assert.Equal(t, int64(1), NewKey[int64]("name").GetOr(loaded, 1))
+
+			userID.Delete(loaded)
+			_, ok = userID.Get(loaded)
+			assert.False(t, ok)
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package redisstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// PayloadCodec encodes the payload of a TypedStore.
+type PayloadCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONPayloadCodec encodes payloads with encoding/json.
+type JSONPayloadCodec struct{}
+
+var _ PayloadCodec = JSONPayloadCodec{}
+
+// Marshal implements PayloadCodec.
+func (JSONPayloadCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v) //nolint: wrapcheck
+}
+
+// Unmarshal implements PayloadCodec.
+func (JSONPayloadCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v) //nolint: wrapcheck
+}
+
+// GobPayloadCodec encodes payloads with encoding/gob.
+type GobPayloadCodec struct{}
+
+var _ PayloadCodec = GobPayloadCodec{}
+
+// Marshal implements PayloadCodec.
+func (GobPayloadCodec) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements PayloadCodec.
+func (GobPayloadCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v) //nolint: wrapcheck
+}
+
+// PayloadCodecFuncs adapts a pair of functions, such as msgpack.Marshal and
+// msgpack.Unmarshal, to a PayloadCodec.
+type PayloadCodecFuncs struct {
+	MarshalFunc   func(v interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, v interface{}) error
+}
+
+var _ PayloadCodec = PayloadCodecFuncs{} //nolint: exhaustruct
+
+// Marshal implements PayloadCodec.
+func (c PayloadCodecFuncs) Marshal(v interface{}) ([]byte, error) {
+	return c.MarshalFunc(v)
+}
+
+// Unmarshal implements PayloadCodec.
+func (c PayloadCodecFuncs) Unmarshal(data []byte, v interface{}) error {
+	return c.UnmarshalFunc(data, v)
+}
+
+// Migration upgrades an encoded payload of one schema version to the next
+// one.
+type Migration func(data []byte) ([]byte, error)
+
+// TypedOptions configures a TypedStore.
+type TypedOptions func(c *typedConfig)
+
+type typedConfig struct {
+	codec      PayloadCodec
+	version    int
+	migrations map[int]Migration
+}
+
+// WithPayloadCodec sets the codec of payloads. By default, payloads are
+// encoded as JSON.
+func WithPayloadCodec(codec PayloadCodec) TypedOptions {
+	return func(c *typedConfig) {
+		c.codec = codec
+	}
+}
+
+// WithSchemaVersion sets the schema version of the payload, which is stored
+// with every session. The default version is 1.
+func WithSchemaVersion(version int) TypedOptions {
+	return func(c *typedConfig) {
+		c.version = version
+	}
+}
+
+// WithMigration registers the migration from schema version from to from+1.
+// Payloads stored with an older version are migrated step by step when they
+// are loaded and stored with the current version on the next Save.
+func WithMigration(from int, migration Migration) TypedOptions {
+	return func(c *typedConfig) {
+		c.migrations[from] = migration
+	}
+}
+
+// typedValueKey is the session value that holds the payload of a typed
+// session.
+const typedValueKey = "_redisstore_payload"
+
+// typedLoadErrorKey is the session value that holds the error of a payload
+// that could not be loaded.
+const typedLoadErrorKey = "_redisstore_load_error"
+
+// ErrSchemaVersion is returned for payloads that can not be migrated to the
+// schema version of a TypedStore.
+var ErrSchemaVersion = errors.New("redisstore: unsupported schema version")
+
+// TypedStore stores sessions whose payload is a struct of type T. The payload
+// is encoded as a whole instead of through the map of session values.
+//
+// Sessions are stored by the underlying Store, with its client, key prefix,
+// session options, cookie codec and transports. The sessions.Session API keeps
+// working through Session and SessionStore; the payload of such a session is
+// the *T in Values under a reserved key. Session binding and the events with
+// changed keys are not supported.
+type TypedStore[T any] struct {
+	store    *Store
+	name     string
+	sessions *typedSessions[T]
+	typedConfig
+}
+
+// NewTypedStore returns a TypedStore for the sessions with the given name. The
+// name must not be used with the Store itself.
+func NewTypedStore[T any](store *Store, name string, options ...TypedOptions) *TypedStore[T] {
+	ts := &TypedStore[T]{
+		store:    store,
+		name:     name,
+		sessions: nil,
+		typedConfig: typedConfig{
+			codec:      JSONPayloadCodec{},
+			version:    1,
+			migrations: map[int]Migration{},
+		},
+	}
+
+	for _, option := range options {
+		option(&ts.typedConfig)
+	}
+
+	ts.sessions = &typedSessions[T]{ts: ts}
+
+	return ts
+}
+
+// Load returns the payload of the request's session. A new, zero payload is
+// returned if the request has no valid session or the session does not exist
+// anymore. The payload is cached for the request, so repeated calls return the
+// same pointer. An error is returned if the stored payload can not be loaded,
+// e.g. ErrSchemaVersion, or if it was replaced by a value of another type
+// through Session.
+func (ts *TypedStore[T]) Load(r *http.Request) (*T, error) {
+	session, err := ts.Session(r)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, ok := session.Values[typedValueKey].(*T)
+	if !ok || payload == nil {
+		return nil, fmt.Errorf("redisstore(load): session has no %T payload", payload)
+	}
+
+	return payload, nil
+}
+
+// Save stores v as the payload of the request's session. It refuses to
+// overwrite a session whose payload could not be loaded.
+func (ts *TypedStore[T]) Save(w http.ResponseWriter, r *http.Request, v *T) error {
+	session, err := ts.Session(r)
+	if err != nil {
+		return err
+	}
+
+	session.Values[typedValueKey] = v
+
+	return ts.sessions.Save(r, w, session)
+}
+
+// Delete deletes the request's session. The cookie of a session whose payload
+// could not be loaded is removed as well.
+func (ts *TypedStore[T]) Delete(w http.ResponseWriter, r *http.Request) error {
+	session, err := ts.Session(r)
+	if session == nil {
+		return err
+	}
+
+	session.Options.MaxAge = -1
+
+	return ts.sessions.Save(r, w, session)
+}
+
+// Session returns the request's session from the registry of gorilla/sessions.
+// A session with an invalid cookie is replaced by a new one. If the stored
+// payload can not be loaded, the session is returned with the error.
+func (ts *TypedStore[T]) Session(r *http.Request) (*sessions.Session, error) {
+	session, err := sessions.GetRegistry(r).Get(ts.sessions, ts.name)
+	if err != nil && session == nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if err, ok := session.Values[typedLoadErrorKey].(error); ok {
+		return session, err
+	}
+
+	return session, nil
+}
+
+// SessionStore returns the sessions.Store of the typed sessions, e.g. for
+// libraries that expect one.
+func (ts *TypedStore[T]) SessionStore() sessions.Store {
+	return ts.sessions
+}
+
+// typedSessions implements sessions.Store for the sessions of a TypedStore.
+type typedSessions[T any] struct {
+	ts *TypedStore[T]
+}
+
+var _ sessions.Store = (*typedSessions[struct{}])(nil)
+
+// Get returns a session for the given name after adding it to the registry.
+func (t *typedSessions[T]) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(t, name) //nolint: wrapcheck
+}
+
+// New returns a session for the given name without adding it to the registry.
+// Its payload is a *T in Values.
+func (t *typedSessions[T]) New(r *http.Request, name string) (*sessions.Session, error) {
+	ts, s := t.ts, t.ts.store
+
+	session := sessions.NewSession(t, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+	session.Values[typedValueKey] = new(T)
+
+	value, _, ok := s.readID(r, name)
+	if !ok {
+		return session, nil
+	}
+
+	id, err := s.codec().Decode(name, value)
+	if err != nil {
+		s.emit(SessionRejected, r, session, nil, err)
+		return session, fmt.Errorf("redisstore(new): decoding cookie value: %v", err)
+	}
+
+	session.ID = id
+
+	payload, err := ts.load(r, id)
+	if err != nil {
+		s.emit(SessionRejected, r, session, nil, err)
+		// Keys left next to a missing session must not be used with it.
+		session.ID = ""
+
+		if errors.Is(err, ErrNotFound) {
+			return session, nil
+		}
+
+		err = fmt.Errorf("redisstore(new): loading payload: %w", err)
+		session.Values[typedLoadErrorKey] = err
+
+		return session, err
+	}
+
+	session.Values[typedValueKey] = payload
+	session.IsNew = false
+
+	return session, nil
+}
+
+// Save stores the payload of a session.
+//
+// If the Options.MaxAge of the session is <= 0, the session is deleted.
+func (t *typedSessions[T]) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ts, s := t.ts, t.ts.store
+
+	if session.Options.MaxAge <= 0 {
+		if err := s.delete(r.Context(), session); err != nil {
+			return fmt.Errorf("redisstore(save): deleting session: %v", err)
+		}
+		s.writeID(r, w, session, "")
+		s.emit(SessionDeleted, r, session, nil, nil)
+
+		return nil
+	}
+
+	if err, ok := session.Values[typedLoadErrorKey].(error); ok {
+		return fmt.Errorf("redisstore(save): session was not loaded: %w", err)
+	}
+
+	payload, ok := session.Values[typedValueKey].(*T)
+	if !ok || payload == nil {
+		return fmt.Errorf("redisstore(save): session has no %T payload", payload)
+	}
+
+	b, err := ts.encode(payload)
+	if err != nil {
+		return fmt.Errorf("redisstore(save): %v", err)
+	}
+
+	if session.ID == "" {
+		session.ID = s.keyGen()
+	}
+
+	maxAge := time.Duration(session.Options.MaxAge) * time.Second
+	if err := s.client.Set(r.Context(), s.SessionKey(session.ID), b, maxAge); err != nil {
+		return fmt.Errorf("redisstore(save): setting session: %v", err)
+	}
+
+	encoded, err := s.codec().Encode(session.Name(), session.ID)
+	if err != nil {
+		return fmt.Errorf("redisstore(save): encoding cookie value: %v", err)
+	}
+
+	s.writeID(r, w, session, encoded)
+
+	if session.IsNew {
+		session.IsNew = false
+		s.emit(SessionCreated, r, session, nil, nil)
+	} else {
+		s.emit(SessionSaved, r, session, nil, nil)
+	}
+
+	return nil
+}
+
+// load reads and migrates the payload of a session.
+func (ts *TypedStore[T]) load(r *http.Request, id string) (*T, error) {
+	val, err := ts.store.client.Get(r.Context(), ts.store.SessionKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("getting session: %w", err)
+	}
+
+	return ts.decode(val)
+}
+
+// encode returns the stored form of a payload: the schema version as uvarint
+// followed by the encoded payload.
+func (ts *TypedStore[T]) encode(payload *T) ([]byte, error) {
+	data, err := ts.codec.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding payload: %v", err)
+	}
+
+	return append(binary.AppendUvarint(nil, uint64(ts.version)), data...), nil
+}
+
+// decode returns the payload of a stored value, migrating it to the current
+// schema version.
+func (ts *TypedStore[T]) decode(val []byte) (*T, error) {
+	stored, n := binary.Uvarint(val)
+	if n <= 0 {
+		return nil, errors.New("decoding payload: invalid schema version")
+	}
+
+	data := val[n:]
+	if stored > uint64(ts.version) {
+		return nil, fmt.Errorf("%w %d", ErrSchemaVersion, stored)
+	}
+
+	for version := int(stored); version < ts.version; version++ {
+		migration, ok := ts.migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w %d: no migration to %d", ErrSchemaVersion, version, version+1)
+		}
+
+		var err error
+		if data, err = migration(data); err != nil {
+			return nil, fmt.Errorf("migrating payload from version %d: %v", version, err)
+		}
+	}
+
+	payload := new(T)
+	if err := ts.codec.Unmarshal(data, payload); err != nil {
+		return nil, fmt.Errorf("decoding payload: %v", err)
+	}
+
+	return payload, nil
+}
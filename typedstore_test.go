@@ -0,0 +1,255 @@
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+type cartV1 struct {
+	Items []string `json:"items"`
+}
+
+type cartV2 struct {
+	Items []cartItem `json:"items"`
+	Owner string     `json:"owner"`
+}
+
+type cartItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+func TestTypedStore(t *testing.T) {
+	codecs := []struct {
+		name  string
+		codec PayloadCodec
+	}{
+		{"json", JSONPayloadCodec{}},
+		{"gob", GobPayloadCodec{}},
+		{"funcs", PayloadCodecFuncs{MarshalFunc: json.Marshal, UnmarshalFunc: json.Unmarshal}},
+	}
+	for _, tt := range codecs {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scanClient{data: map[string][]byte{}}
+			store := New(client, [][]byte{[]byte("key")}, WithKeyPrefix("typed_"))
+			carts := NewTypedStore[cartV2](store, "cart", WithPayloadCodec(tt.codec))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			res := httptest.NewRecorder()
+
+			cart, err := carts.Load(req)
+			assert.NoError(t, err)
+			assert.Equal(t, &cartV2{}, cart)
+
+			cart.Owner = "a"
+			cart.Items = append(cart.Items, cartItem{SKU: "x", Quantity: 2})
+			assert.NoError(t, carts.Save(res, req, cart))
+
+			session, err := carts.Session(req)
+			assert.NoError(t, err)
+			assert.Contains(t, client.data, "typed_"+session.ID)
+
+			cookie := res.Result().Cookies()[0]
+			assert.Equal(t, "cart", cookie.Name)
+			assert.Equal(t, store.Options.MaxAge, cookie.MaxAge)
+
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookie)
+			loaded, err := carts.Load(req)
+			assert.NoError(t, err)
+			assert.Equal(t, cart, loaded)
+
+			// The payload is cached for the request.
+			again, err := carts.Load(req)
+			assert.NoError(t, err)
+			assert.Same(t, loaded, again)
+
+			res = httptest.NewRecorder()
+			assert.NoError(t, carts.Delete(res, req))
+			assert.Empty(t, client.data)
+			assert.Equal(t, -1, res.Result().Cookies()[0].MaxAge)
+		})
+	}
+}
+
+func TestTypedStoreSessionsAPI(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+	carts := NewTypedStore[cartV2](store, "cart")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+
+	session, err := carts.SessionStore().Get(req, "cart")
+	assert.NoError(t, err)
+	assert.True(t, session.IsNew)
+	session.Values[typedValueKey].(*cartV2).Owner = "a"
+	assert.NoError(t, sessions.Save(req, res))
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(res.Result().Cookies()[0])
+	cart, err := carts.Load(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", cart.Owner)
+
+	// Invalid cookies are replaced by a new session.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "cart", Value: "invalid"})
+	cart, err = carts.Load(req)
+	assert.NoError(t, err)
+	assert.Equal(t, &cartV2{}, cart)
+
+	// A payload of another type is an error instead of a panic.
+	session, err = carts.Session(req)
+	assert.NoError(t, err)
+	session.Values[typedValueKey] = cartV1{}
+	_, err = carts.Load(req)
+	assert.Error(t, err)
+}
+
+func TestTypedStoreEvents(t *testing.T) {
+	rec := &recorder{}
+	store := New(&scanClient{data: map[string][]byte{}}, [][]byte{[]byte("key")}, WithEventHandler(rec))
+	carts := NewTypedStore[cartV2](store, "cart")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	cart, err := carts.Load(req)
+	assert.NoError(t, err)
+
+	// Only the first save of a session creates it.
+	assert.NoError(t, carts.Save(httptest.NewRecorder(), req, cart))
+	assert.NoError(t, carts.Save(httptest.NewRecorder(), req, cart))
+	assert.Equal(t, []EventType{SessionCreated, SessionSaved}, rec.types())
+
+	session, err := carts.Session(req)
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew)
+}
+
+func TestTypedStoreMigrations(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	v1 := NewTypedStore[cartV1](store, "cart")
+	assert.NoError(t, v1.Save(res, req, &cartV1{Items: []string{"x", "y"}}))
+	cookie := res.Result().Cookies()[0]
+
+	load := func(ts *TypedStore[cartV2]) (*cartV2, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(cookie)
+
+		cart, err := ts.Load(req)
+		assert.NoError(t, err)
+
+		session, err := ts.Session(req)
+		assert.NoError(t, err)
+
+		return cart, session.IsNew
+	}
+
+	// Without a migration, the stored session can not be loaded.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	_, err := NewTypedStore[cartV2](store, "cart", WithSchemaVersion(2)).Load(req)
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+
+	v2 := NewTypedStore[cartV2](store, "cart", WithSchemaVersion(2), WithMigration(1, func(data []byte) ([]byte, error) {
+		var old cartV1
+		if err := json.Unmarshal(data, &old); err != nil {
+			return nil, err
+		}
+
+		cart := cartV2{Items: make([]cartItem, 0, len(old.Items)), Owner: ""}
+		for _, sku := range old.Items {
+			cart.Items = append(cart.Items, cartItem{SKU: sku, Quantity: 1})
+		}
+
+		return json.Marshal(cart)
+	}))
+
+	cart, isNew := load(v2)
+	assert.False(t, isNew)
+	assert.Equal(t, []cartItem{{SKU: "x", Quantity: 1}, {SKU: "y", Quantity: 1}}, cart.Items)
+
+	// Newer schema versions are not loaded by older stores.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	assert.NoError(t, v2.Save(httptest.NewRecorder(), req, cart))
+
+	_, err = v1.decode(client.data[store.SessionKey(cookieID(t, store, cookie))])
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+}
+
+func TestTypedStoreLoadError(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+	v1 := NewTypedStore[cartV1](store, "cart")
+	v2 := NewTypedStore[cartV2](store, "cart", WithSchemaVersion(2))
+
+	res := httptest.NewRecorder()
+	assert.NoError(t, v2.Save(res, httptest.NewRequest(http.MethodGet, "/", nil), &cartV2{Owner: "a"}))
+	cookie := res.Result().Cookies()[0]
+	key := store.SessionKey(cookieID(t, store, cookie))
+	stored := client.data[key]
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+
+	_, err := v1.Load(req)
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+
+	// The newer payload is not overwritten.
+	err = v1.Save(httptest.NewRecorder(), req, &cartV1{Items: []string{"x"}})
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+	assert.Len(t, client.data, 1)
+	assert.Equal(t, stored, client.data[key])
+
+	res = httptest.NewRecorder()
+	assert.NoError(t, v1.Delete(res, req))
+	assert.Equal(t, -1, res.Result().Cookies()[0].MaxAge)
+}
+
+func TestTypedStoreDeletedSession(t *testing.T) {
+	client := &scanClient{data: map[string][]byte{}}
+	store := New(client, [][]byte{[]byte("key")})
+	carts := NewTypedStore[cartV2](store, "cart")
+
+	res := httptest.NewRecorder()
+	assert.NoError(t, carts.Save(res, httptest.NewRequest(http.MethodGet, "/", nil), &cartV2{Owner: "a"}))
+	cookie := res.Result().Cookies()[0]
+	id := cookieID(t, store, cookie)
+
+	admin, err := NewAdmin(store)
+	assert.NoError(t, err)
+	assert.NoError(t, admin.Delete(context.Background(), id))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookie)
+	cart, err := carts.Load(req)
+	assert.NoError(t, err)
+	assert.Equal(t, &cartV2{}, cart)
+
+	// The deleted session does not come back under its old ID.
+	res = httptest.NewRecorder()
+	assert.NoError(t, carts.Save(res, req, cart))
+	assert.NotContains(t, client.data, store.SessionKey(id))
+	assert.NotEqual(t, id, cookieID(t, store, res.Result().Cookies()[0]))
+}
+
+// cookieID returns the session ID of a cookie.
+func cookieID(t *testing.T, store *Store, cookie *http.Cookie) string {
+	t.Helper()
+
+	id, err := store.codec().Decode(cookie.Name, cookie.Value)
+	assert.NoError(t, err)
+
+	return id
+}